@@ -0,0 +1,255 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// assetFile is a local file to upload as a release asset. Its label and content type,
+// when given, override the ones the asset would otherwise get.
+type assetFile struct {
+	path        string
+	name        string
+	label       string
+	contentType string
+}
+
+// newAssetFiles returns the files to upload as assets named after the files.
+func newAssetFiles(filepaths []string) []assetFile {
+	files := make([]assetFile, len(filepaths))
+	for i, path := range filepaths {
+		files[i] = assetFile{path: path, name: filepath.Base(path)}
+	}
+	return files
+}
+
+// expandAssetPattern expands the <files> glob pattern, which can end with #<label> to give
+// a label to the matching assets, e.g. "dist/app-linux#Linux x86_64 binary". A pattern
+// matching files as is, # included, is not split.
+func expandAssetPattern(pattern string) ([]string, string) {
+	filepaths := expandGlob(pattern)
+	i := strings.Index(pattern, "#")
+	if i < 0 || len(filepaths) > 0 {
+		return filepaths, ""
+	}
+	return expandGlob(pattern[:i]), pattern[i+1:]
+}
+
+// globAssetFiles returns the files to upload for the <files> glob pattern, labelled as
+// the pattern says if it does, and named as -rename says.
+func globAssetFiles(pattern string) []assetFile {
+	filepaths, label := expandAssetPattern(pattern)
+	files := newAssetFiles(filepaths)
+	for i := range files {
+		files[i].label = label
+		files[i].name = renameFlags.rename(files[i])
+	}
+	return files
+}
+
+// globPatterns returns the files to upload for all the patterns given after <description>,
+// matched by any of them. Patterns matching no file are warned about, or fail the run under
+// -strict-files.
+func globPatterns(patterns []string) []assetFile {
+	var files []assetFile
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		if pattern == "-" {
+			fatalln("Error: - cannot be combined with other patterns, stdin has to be uploaded alone")
+		}
+
+		globbed := globAssetFiles(pattern)
+		if len(globbed) == 0 && pattern != "" {
+			if strictFilesFlag {
+				fatalf("Error: No file matches %q, not creating the release as -strict-files was given\n", pattern)
+			}
+			log.Printf("Warning: No file matches %q, nothing will be uploaded for it\n", pattern)
+		}
+
+		for _, f := range globbed {
+			if !seen[f.path] {
+				seen[f.path] = true
+				files = append(files, f)
+			}
+		}
+	}
+
+	for from := range renameFlags {
+		if !renameFlags.matchesAny(from, files) {
+			log.Printf("Warning: -rename %s matches none of the files, nothing is renamed for it\n", from)
+		}
+	}
+	return files
+}
+
+// stdinAssetFile buffers stdin to a temporary file, removed by removeTempDirs, to be
+// uploaded as the named asset. Github needs the size of an asset before receiving it.
+func stdinAssetFile(name string) (assetFile, error) {
+	dir, err := newTempDir()
+	if err != nil {
+		return assetFile{}, err
+	}
+
+	path := filepath.Join(dir, filepath.Base(name))
+	f, err := os.Create(path)
+	if err != nil {
+		return assetFile{}, err
+	}
+	if _, err := io.Copy(f, os.Stdin); err != nil {
+		f.Close()
+		return assetFile{}, err
+	}
+	return assetFile{path: path, name: name}, f.Close()
+}
+
+// assetFileList implements flag.Value for the repeatable -asset name:/path/to/file option.
+type assetFileList []assetFile
+
+func (l *assetFileList) String() string {
+	entries := make([]string, len(*l))
+	for i, f := range *l {
+		entries[i] = f.name + ":" + f.path
+	}
+	return strings.Join(entries, ",")
+}
+
+func (l *assetFileList) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected name:/path/to/file, got %q", value)
+	}
+	*l = append(*l, assetFile{path: parts[1], name: parts[0]})
+	return nil
+}
+
+// renameList implements flag.Value for the repeatable -rename <file>=<name> option, mapping
+// the path or base name of files matched by <files> to the name of their asset.
+type renameList map[string]string
+
+func (l *renameList) String() string {
+	entries := make([]string, 0, len(*l))
+	for from, to := range *l {
+		entries = append(entries, from+"="+to)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+func (l *renameList) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected <file>=<name>, got %q", value)
+	}
+	if strings.ContainsAny(parts[1], `/\`) {
+		return fmt.Errorf("asset name %q cannot contain a path separator", parts[1])
+	}
+	if *l == nil {
+		*l = make(renameList)
+	}
+	(*l)[parts[0]] = parts[1]
+	return nil
+}
+
+// rename returns the name of the asset for the file, as given by -rename for its path, or
+// else for its base name, or else its current name.
+func (l renameList) rename(f assetFile) string {
+	if name, ok := l[f.path]; ok {
+		return name
+	}
+	if name, ok := l[filepath.Base(f.path)]; ok {
+		return name
+	}
+	return f.name
+}
+
+// matchesAny tells whether the -rename entry for from applies to any of the files.
+func (l renameList) matchesAny(from string, files []assetFile) bool {
+	for _, f := range files {
+		if f.path == from || filepath.Base(f.path) == from {
+			return true
+		}
+	}
+	return false
+}
+
+// collectAssetFiles unions the globbed files with the ones given by -asset, making sure
+// the latter exist and that no two files would be uploaded under the same name.
+func collectAssetFiles(globbed []assetFile, extra []assetFile) ([]assetFile, error) {
+	for _, f := range extra {
+		stat, err := os.Stat(f.path)
+		if err != nil {
+			return nil, fmt.Errorf("Error: Invalid -asset %s:%s: %s", f.name, f.path, err)
+		}
+		if !stat.Mode().IsRegular() {
+			return nil, fmt.Errorf("Error: Invalid -asset %s:%s: not a regular file", f.name, f.path)
+		}
+	}
+
+	files := append(globbed, extra...)
+	paths := make(map[string]string, len(files))
+	for _, f := range files {
+		if other, ok := paths[f.name]; ok {
+			return nil, fmt.Errorf("Error: %s and %s would both be uploaded as %s", other, f.path, f.name)
+		}
+		paths[f.name] = f.path
+	}
+	return files, nil
+}
+
+// loadAssetSpec reads the files to upload from the JSON file given by -assets-from, which
+// holds an array of objects with the path of each file and, optionally, the name, label
+// and content type of its asset.
+func loadAssetSpec(path string) ([]assetFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []struct {
+		Path        string `json:"path"`
+		Name        string `json:"name"`
+		Label       string `json:"label"`
+		ContentType string `json:"content_type"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("Error: Invalid -assets-from %s: %s", path, err)
+	}
+
+	files := make([]assetFile, len(entries))
+	for i, e := range entries {
+		if e.Path == "" {
+			return nil, fmt.Errorf("Error: Invalid -assets-from %s: entry %d has no path", path, i+1)
+		}
+		stat, err := os.Stat(e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("Error: Invalid -assets-from %s: %s", path, err)
+		}
+		if !stat.Mode().IsRegular() {
+			return nil, fmt.Errorf("Error: Invalid -assets-from %s: %s is not a regular file", path, e.Path)
+		}
+		if e.ContentType != "" {
+			if _, _, err := mime.ParseMediaType(e.ContentType); err != nil {
+				return nil, fmt.Errorf("Error: Invalid -assets-from %s: invalid content type for %s: %s", path, e.Path, e.ContentType)
+			}
+		}
+
+		name := e.Name
+		if name == "" {
+			name = filepath.Base(e.Path)
+		}
+		files[i] = assetFile{path: e.Path, name: name, label: e.Label, contentType: e.ContentType}
+	}
+	return files, nil
+}
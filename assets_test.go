@@ -0,0 +1,87 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// withStdin makes os.Stdin read the given content for the duration of the test.
+func withStdin(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func TestStdinAssetFileIsRemoved(t *testing.T) {
+	tmpDirFlag = t.TempDir()
+	defer func() { tmpDirFlag = "" }()
+	withStdin(t, "hello")
+
+	file, err := stdinAssetFile("a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.name != "a.bin" {
+		t.Errorf("name = %q, want a.bin", file.name)
+	}
+	data, err := ioutil.ReadFile(file.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("content = %q, want hello", data)
+	}
+
+	removeTempDirs()
+	if _, err := os.Stat(file.path); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after removeTempDirs: %v", file.path, err)
+	}
+}
+
+// TestStdinAssetFileIsRemovedOnFatalError runs itself in a subprocess which buffers stdin and
+// then fails, as a run does when the release cannot be created.
+func TestStdinAssetFileIsRemovedOnFatalError(t *testing.T) {
+	if dir := os.Getenv("GITHUB_RELEASE_TEST_TMP_DIR"); dir != "" {
+		tmpDirFlag = dir
+		withStdin(t, "hello")
+		if _, err := stdinAssetFile("a.bin"); err != nil {
+			t.Fatal(err)
+		}
+		fatalln("Error: Unable to create the release")
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStdinAssetFileIsRemovedOnFatalError$")
+	cmd.Env = append(os.Environ(), "GITHUB_RELEASE_TEST_TMP_DIR="+dir)
+	out, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("subprocess exited with %v, want status 1:\n%s", err, out)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("%s was left behind", filepath.Join(dir, e.Name()))
+	}
+}
@@ -0,0 +1,49 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import "sync"
+
+// assetCache keeps the assets of the releases looked up during a run, indexed by
+// release ID, so that they are not listed again for every file and retry. Entries
+// are dropped whenever assets are uploaded or deleted, and listed again on demand.
+var assetCache = struct {
+	sync.Mutex
+	assets map[int64][]Asset
+	// generation is bumped on every invalidation, so that a listing started
+	// before assets changed does not end up in the cache.
+	generation int
+}{assets: make(map[int64][]Asset)}
+
+// cachedAssets returns the assets of the release, listing them only if not cached.
+func cachedAssets(release Release) ([]Asset, error) {
+	assetCache.Lock()
+	assets, ok := assetCache.assets[release.ID]
+	generation := assetCache.generation
+	assetCache.Unlock()
+	if ok {
+		return assets, nil
+	}
+
+	assets, err := listAssets(release)
+	if err != nil {
+		return nil, err
+	}
+
+	assetCache.Lock()
+	if assetCache.generation == generation {
+		assetCache.assets[release.ID] = assets
+	}
+	assetCache.Unlock()
+	return assets, nil
+}
+
+// invalidateAssets drops the cached assets of the release, which have changed.
+func invalidateAssets(release Release) {
+	assetCache.Lock()
+	delete(assetCache.assets, release.ID)
+	assetCache.generation++
+	assetCache.Unlock()
+}
@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// assetServer serves the assets of release 1, counting the listings.
+type assetServer struct {
+	mu       sync.Mutex
+	assets   []Asset
+	listings int
+	// started and proceed, when set, hold a listing until the test lets it go.
+	started chan struct{}
+	proceed chan struct{}
+}
+
+func (s *assetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/repos/o/r/releases/1/assets" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	s.listings++
+	assets := append([]Asset{}, s.assets...)
+	started, proceed := s.started, s.proceed
+	s.mu.Unlock()
+
+	if started != nil {
+		close(started)
+		<-proceed
+	}
+	json.NewEncoder(w).Encode(assets)
+}
+
+func (s *assetServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listings
+}
+
+func newAssetServer(t *testing.T, assets ...Asset) *assetServer {
+	s := &assetServer{assets: assets}
+	useTestServer(t, 5*time.Second, s.ServeHTTP)
+
+	assetCache.Lock()
+	assetCache.assets = make(map[int64][]Asset)
+	assetCache.Unlock()
+	return s
+}
+
+func TestCachedAssets(t *testing.T) {
+	s := newAssetServer(t, Asset{ID: 10, Name: "a", Size: 1})
+	release := Release{ID: 1}
+
+	for i := 0; i < 3; i++ {
+		assets, err := cachedAssets(release)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(assets) != 1 || assets[0].Name != "a" {
+			t.Fatalf("cachedAssets = %+v, want asset a", assets)
+		}
+	}
+	if n := s.count(); n != 1 {
+		t.Errorf("assets listed %d times, want once", n)
+	}
+
+	s.mu.Lock()
+	s.assets = append(s.assets, Asset{ID: 11, Name: "b", Size: 2})
+	s.mu.Unlock()
+	invalidateAssets(release)
+
+	assets, err := cachedAssets(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 2 {
+		t.Errorf("cachedAssets after invalidation = %+v, want assets a and b", assets)
+	}
+	if n := s.count(); n != 2 {
+		t.Errorf("assets listed %d times, want twice", n)
+	}
+}
+
+func TestCachedAssetsDropsListingOutdatedByInvalidation(t *testing.T) {
+	s := newAssetServer(t, Asset{ID: 10, Name: "a", Size: 1})
+	release := Release{ID: 1}
+
+	s.mu.Lock()
+	s.started, s.proceed = make(chan struct{}), make(chan struct{})
+	started := s.started
+	s.mu.Unlock()
+
+	done := make(chan []Asset)
+	go func() {
+		assets, err := cachedAssets(release)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- assets
+	}()
+
+	// The asset changes while it is being listed.
+	<-started
+	s.mu.Lock()
+	s.assets[0].Size = 2
+	proceed := s.proceed
+	s.started, s.proceed = nil, nil
+	s.mu.Unlock()
+	invalidateAssets(release)
+	close(proceed)
+
+	if assets := <-done; len(assets) != 1 || assets[0].Size != 1 {
+		t.Fatalf("outdated listing = %+v, want the asset as it was", assets)
+	}
+
+	assets, err := cachedAssets(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 1 || assets[0].Size != 2 {
+		t.Errorf("cachedAssets = %+v, want the asset as changed, not the outdated listing", assets)
+	}
+	if n := s.count(); n != 2 {
+		t.Errorf("assets listed %d times, want twice", n)
+	}
+}
@@ -0,0 +1,104 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// checksumsAssetName is the name of the release asset holding the SHA-256 of the other assets.
+const checksumsAssetName = "SHA256SUMS"
+
+// checkRelease compares the assets of the release for tag, draft or not, against the local
+// files, under the names they would be uploaded as, logging every difference found. It
+// returns whether both are in sync.
+func checkRelease(tag string, files []assetFile) bool {
+	release, err := findReleaseByTag(tag)
+	if err != nil {
+		fatalln(err)
+	}
+
+	assets, err := listAssets(release)
+	if err != nil {
+		fatalln(err)
+	}
+
+	remote := make(map[string]Asset)
+	var sums map[string]string
+	for _, a := range assets {
+		remote[a.Name] = a
+		if a.Name != checksumsAssetName {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := downloadAsset(a, &buf); err != nil {
+			fatalln(err)
+		}
+		if sums, err = parseChecksums(&buf); err != nil {
+			fatalln(err)
+		}
+	}
+
+	var diff []string
+	local := make(map[string]bool)
+	for _, f := range files {
+		name := f.name
+		local[name] = true
+
+		stat, err := os.Stat(f.path)
+		if err != nil {
+			fatalln(err)
+		}
+
+		asset, ok := remote[name]
+		if !ok {
+			diff = append(diff, "- "+name+": missing from the release")
+			continue
+		}
+
+		if asset.Size != stat.Size() {
+			diff = append(diff, fmt.Sprintf("~ %s: size differs (release %d, local %d)", name, asset.Size, stat.Size()))
+			continue
+		}
+
+		sum, ok := sums[name]
+		if !ok {
+			continue
+		}
+
+		localSum, err := fileSHA256(f.path)
+		if err != nil {
+			fatalln(err)
+		}
+		if !strings.EqualFold(sum, localSum) {
+			diff = append(diff, fmt.Sprintf("~ %s: sha256 differs (release %s, local %s)", name, sum, localSum))
+		}
+	}
+
+	for name := range remote {
+		// The checksums file describes the release, it is not expected locally.
+		if !local[name] && name != checksumsAssetName {
+			diff = append(diff, "+ "+name+": not among the local files")
+		}
+	}
+
+	if len(diff) == 0 {
+		log.Printf("Release %s is in sync with the local files.\n", tag)
+		return true
+	}
+
+	sort.Strings(diff)
+	log.Printf("Release %s is out of sync with the local files:\n", tag)
+	for _, d := range diff {
+		log.Println(d)
+	}
+	return false
+}
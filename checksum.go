@@ -0,0 +1,238 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hashAlgorithms lists the algorithms supported by -hash.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Formats supported by -manifest-format.
+const (
+	manifestSums = "sums"
+	manifestJSON = "json"
+	manifestCSV  = "csv"
+)
+
+// fileChecksums holds the checksums of a file, indexed by hash algorithm.
+type fileChecksums struct {
+	name string
+	size int64
+	sums map[string]string
+}
+
+// checksumLabelPrefix starts the labels given to assets under -checksum-label.
+const checksumLabelPrefix = "sha256:"
+
+// fileSHA256 computes the hex encoded SHA-256 of a file without loading it in memory.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseChecksums reads a file in the format produced by sha256sum, i.e. one
+// "<hash>  <name>" line per file, returning the hashes indexed by file name.
+func parseChecksums(r io.Reader) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		// Binary mode entries are prefixed with an asterisk.
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums, scanner.Err()
+}
+
+// hashNames returns the algorithms requested by -hash.
+func hashNames() []string {
+	var names []string
+	for _, name := range strings.Split(hashFlag, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkManifestOptions validates -hash and -manifest-format.
+func checkManifestOptions() error {
+	names := hashNames()
+	if len(names) == 0 {
+		return fmt.Errorf("Error: -hash needs at least one algorithm")
+	}
+	for _, name := range names {
+		if _, ok := hashAlgorithms[name]; !ok {
+			return fmt.Errorf("Error: Unsupported hash algorithm: %s", name)
+		}
+	}
+	if checksumsNameFlag != "" && len(names) > 1 {
+		return fmt.Errorf("Error: -checksums-name can only be given along with a single -hash algorithm")
+	}
+	if strings.ContainsAny(checksumsNameFlag, `/\`) {
+		return fmt.Errorf("Error: Invalid -checksums-name value: %s, a file name is expected", checksumsNameFlag)
+	}
+
+	switch manifestFormatFlag {
+	case manifestSums, manifestJSON, manifestCSV:
+		return nil
+	}
+	return fmt.Errorf("Error: Unsupported manifest format: %s", manifestFormatFlag)
+}
+
+// computeChecksums hashes the file with every given algorithm, reading it only once.
+func computeChecksums(asset assetFile, algorithms []string) (fileChecksums, error) {
+	result := fileChecksums{name: asset.name, sums: make(map[string]string)}
+
+	file, err := os.Open(asset.path)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+
+	hashes := make([]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, len(algorithms))
+	for i, name := range algorithms {
+		hashes[i] = hashAlgorithms[name]()
+		writers[i] = hashes[i]
+	}
+
+	if result.size, err = io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return result, err
+	}
+	for i, name := range algorithms {
+		result.sums[name] = hex.EncodeToString(hashes[i].Sum(nil))
+	}
+	return result, nil
+}
+
+// manifestName returns the name of the manifest listing checksums of the given algorithm,
+// given by -checksums-name if set.
+func manifestName(algorithm string) string {
+	if checksumsNameFlag != "" {
+		return checksumsNameFlag
+	}
+	name := strings.ToUpper(algorithm) + "SUMS"
+	if manifestFormatFlag != manifestSums {
+		name += "." + manifestFormatFlag
+	}
+	return name
+}
+
+// writeManifest writes the checksums of the given algorithm in the -manifest-format format.
+func writeManifest(w io.Writer, algorithm string, checksums []fileChecksums) error {
+	switch manifestFormatFlag {
+	case manifestJSON:
+		entries := make([]map[string]interface{}, len(checksums))
+		for i, c := range checksums {
+			entries[i] = map[string]interface{}{"name": c.name, algorithm: c.sums[algorithm], "size": c.size}
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+
+	case manifestCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", algorithm, "size"})
+		for _, c := range checksums {
+			cw.Write([]string{c.name, c.sums[algorithm], strconv.FormatInt(c.size, 10)})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	for _, c := range checksums {
+		if _, err := fmt.Fprintf(w, "%s  %s\n", c.sums[algorithm], c.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withoutManifests leaves out of the files the ones named like a manifest generated by
+// -checksums, e.g. left over from a previous build, which the manifest replaces and
+// which are not to be listed in it.
+func withoutManifests(files []assetFile) []assetFile {
+	names := make(map[string]bool)
+	for _, algorithm := range hashNames() {
+		names[manifestName(algorithm)] = true
+	}
+
+	var kept []assetFile
+	for _, f := range files {
+		if names[f.name] {
+			infof("Leaving out %s, replaced by the generated checksums manifest.\n", f.path)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// generateManifests writes in dir one manifest per -hash algorithm listing the checksums
+// of the files, returning their paths.
+func generateManifests(dir string, files []assetFile) ([]string, error) {
+	algorithms := hashNames()
+
+	checksums := make([]fileChecksums, len(files))
+	for i, f := range files {
+		var err error
+		if checksums[i], err = computeChecksums(f, algorithms); err != nil {
+			return nil, err
+		}
+	}
+
+	var manifests []string
+	for _, algorithm := range algorithms {
+		path := filepath.Join(dir, manifestName(algorithm))
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeManifest(file, algorithm, checksums)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, path)
+	}
+	return manifests, nil
+}
@@ -0,0 +1,205 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultConfigFile is the configuration file read from the working directory, if it
+// exists, when -config is not given.
+const defaultConfigFile = ".github-release.yml"
+
+// config holds the settings of a configuration file, a flat YAML mapping such as
+//
+//	token: ghp_xxx
+//	api: https://github.example.com/api/v3
+//	user: octocat
+//	repo: hello-world
+//	retries: 3
+//	prerelease: yes
+//
+// token, api, user and repo stand for GITHUB_TOKEN, GITHUB_API, GITHUB_USER and GITHUB_REPO,
+// while any other key gives the default value of the option with that name.
+type config struct {
+	path   string
+	token  string
+	api    string
+	user   string
+	repo   string
+	flags  map[string]string
+	lineOf map[string]int
+}
+
+// flagEnvs are the environment variables providing the default value of an option, which
+// take precedence over the configuration file.
+var flagEnvs = map[string][]string{
+	"retries":        {"GITHUB_RELEASE_RETRIES"},
+	"upload-retries": {"GITHUB_UPLOAD_RETRIES"},
+	"timeout":        {"GITHUB_RELEASE_TIMEOUT", "GITHUB_HTTP_TIMEOUT"},
+	"concurrency":    {"GITHUB_RELEASE_CONCURRENCY"},
+	"upload-url":     {"GITHUB_UPLOAD_API"},
+	"token-file":     {"GITHUB_TOKEN_FILE"},
+}
+
+// loadConfig reads the configuration file given by -config, or else .github-release.yml
+// if there is one, and applies it. Options given on the command line and environment
+// variables take precedence over it.
+func loadConfig() error {
+	path := configFlag
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err != nil {
+			return nil
+		}
+		path = defaultConfigFile
+	}
+
+	cfg, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	return cfg.apply()
+}
+
+// readConfig parses a configuration file made of key: value lines, with # comments and
+// optionally quoted values.
+func readConfig(path string) (*config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error: Unable to read the configuration file: %s", err)
+	}
+	defer f.Close()
+
+	cfg := &config{path: path, flags: make(map[string]string), lineOf: make(map[string]int)}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("Error: %s:%d: expected key: value, got %q", path, n, line)
+		}
+		value, err := configValue(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Error: %s:%d: %s", path, n, err)
+		}
+
+		switch key {
+		case "token":
+			cfg.token = value
+		case "api":
+			cfg.api = value
+		case "user":
+			cfg.user = value
+		case "repo":
+			cfg.repo = value
+		default:
+			if flag.Lookup(key) == nil || key == "config" {
+				return nil, fmt.Errorf("Error: %s:%d: unknown option %q", path, n, key)
+			}
+			cfg.flags[key] = value
+			cfg.lineOf[key] = n
+		}
+	}
+	return cfg, scanner.Err()
+}
+
+// configValue unquotes a value and strips its trailing comment.
+func configValue(value string) (string, error) {
+	if strings.HasPrefix(value, `"`) {
+		end := strings.Index(value[1:], `"`)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", value)
+		}
+		return strconv.Unquote(value[:end+2])
+	}
+	if strings.HasPrefix(value, "'") {
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", value)
+		}
+		return value[1 : end+1], nil
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// apply sets what the environment and the command line leave unset.
+func (cfg *config) apply() error {
+	if os.Getenv("GITHUB_TOKEN") == "" && cfg.token != "" {
+		githubToken = cfg.token
+	}
+	if os.Getenv("GITHUB_API") == "" && cfg.api != "" {
+		githubAPIEndpoint = cfg.api
+	}
+	if os.Getenv("GITHUB_USER") == "" && cfg.user != "" {
+		githubUser = cfg.user
+	}
+	if os.Getenv("GITHUB_REPO") == "" && cfg.repo != "" {
+		githubRepo = cfg.repo
+	}
+
+	for name, value := range cfg.flags {
+		if isFlagSet(name) || envSet(flagEnvs[name]) {
+			continue
+		}
+		if b, ok := flag.Lookup(name).Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			value = yamlBool(value)
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("Error: %s:%d: invalid value %q for %s: %s "+
+				"(options given on the command line and environment variables take precedence over %s)",
+				cfg.path, cfg.lineOf[name], value, name, err, cfg.path)
+		}
+	}
+	return nil
+}
+
+// yamlBool turns the YAML spellings of booleans, such as yes or off, into the true or false
+// boolean options understand, leaving other values as they are.
+func yamlBool(value string) string {
+	switch strings.ToLower(value) {
+	case "y", "yes", "on", "true":
+		return "true"
+	case "n", "no", "off", "false":
+		return "false"
+	}
+	return value
+}
+
+// useConfiguredRepo puts the user and repository given by the configuration file, or by
+// GITHUB_USER and GITHUB_REPO, in front of the arguments when <user/repo> is left out, i.e.
+// when the first argument is not a repository. A <tag> with a slash is taken for one, in
+// which case <user/repo> has to be given.
+func useConfiguredRepo() {
+	if githubUser == "" || githubRepo == "" {
+		return
+	}
+	if _, _, err := parseRepo(flag.Arg(0)); err == nil {
+		return
+	}
+	flag.CommandLine.Parse(append([]string{"--", githubUser + "/" + githubRepo}, flag.Args()...))
+}
+
+// envSet tells whether any of the environment variables is set.
+func envSet(names []string) bool {
+	for _, name := range names {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultContentType = "application/octet-stream"
+
+// signature is a sequence of bytes expected at a given offset of a file.
+// Negative offsets are relative to the end of the file.
+type signature struct {
+	offset int64
+	bytes  []byte
+}
+
+// magicNumber maps a set of signatures, all of which have to match, to a content type.
+type magicNumber struct {
+	signatures  []signature
+	contentType string
+}
+
+// magicNumbers lists common release formats http.DetectContentType does not recognize.
+var magicNumbers = []magicNumber{
+	{[]signature{{0, []byte("!<arch>\ndebian-binary")}}, "application/vnd.debian.binary-package"},
+	{[]signature{{0, []byte{0xed, 0xab, 0xee, 0xdb}}}, "application/x-rpm"},
+	{[]signature{{0, []byte("\x7fELF")}, {8, []byte("AI\x01")}}, "application/vnd.appimage"},
+	{[]signature{{0, []byte("\x7fELF")}, {8, []byte("AI\x02")}}, "application/vnd.appimage"},
+	{[]signature{{0, []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}}}, "application/x-msi"},
+	{[]signature{{32769, []byte("CD001")}}, "application/x-iso9660-image"},
+	{[]signature{{-512, []byte("koly")}}, "application/x-apple-diskimage"},
+}
+
+// detectContentType guesses the content type of a file by looking at its first
+// sniffLen bytes, plus its trailer for formats identified by one, and else at the
+// extension of its name. A negative size means the size of the file is unknown, in
+// which case trailers are not looked at.
+func detectContentType(name string, file io.ReaderAt, size int64, sniffLen int) (string, error) {
+	head := make([]byte, sniffLen)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+
+	for _, m := range magicNumbers {
+		ok, err := m.match(file, size, head)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return m.contentType, nil
+		}
+	}
+
+	// Sniffing tells little about text formats, such as JSON or YAML, which
+	// all come out as text/plain.
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType, nil
+	}
+	if len(head) == 0 {
+		return defaultContentType, nil
+	}
+	return http.DetectContentType(head), nil
+}
+
+func (m magicNumber) match(file io.ReaderAt, size int64, head []byte) (bool, error) {
+	for _, s := range m.signatures {
+		offset := s.offset
+		if offset < 0 {
+			if size < 0 {
+				return false, nil
+			}
+			offset += size
+		}
+		if offset < 0 || (size >= 0 && offset+int64(len(s.bytes)) > size) {
+			return false, nil
+		}
+
+		var data []byte
+		if s.offset >= 0 {
+			// Signatures beyond the sniffing window are not considered.
+			if offset+int64(len(s.bytes)) > int64(len(head)) {
+				return false, nil
+			}
+			data = head[offset : offset+int64(len(s.bytes))]
+		} else {
+			data = make([]byte, len(s.bytes))
+			if _, err := file.ReadAt(data, offset); err != nil {
+				return false, err
+			}
+		}
+
+		if !bytes.Equal(data, s.bytes) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// contentTypeRule sets the content type of the files matching a glob pattern.
+type contentTypeRule struct {
+	pattern     string
+	contentType string
+}
+
+// contentTypeRules holds the rules loaded from -content-type-rules, in file order.
+var contentTypeRules []contentTypeRule
+
+// loadContentTypeRules parses a file of glob=content/type lines. Empty lines
+// and lines starting with # are ignored.
+func loadContentTypeRules(path string) ([]contentTypeRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []contentTypeRule
+	scanner := bufio.NewScanner(file)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Error: %s:%d: Expected glob=content/type, got %q", path, lineno, line)
+		}
+
+		rule := contentTypeRule{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])}
+		if _, err := filepath.Match(rule.pattern, ""); err != nil {
+			return nil, fmt.Errorf("Error: %s:%d: Invalid glob pattern: %s", path, lineno, rule.pattern)
+		}
+		if _, _, err := mime.ParseMediaType(rule.contentType); err != nil {
+			return nil, fmt.Errorf("Error: %s:%d: Invalid content type: %s", path, lineno, rule.contentType)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// match tells whether the rule applies to the file. Patterns without a slash
+// are matched against the asset name, others against the local path.
+func (r contentTypeRule) match(file assetFile) bool {
+	s := file.name
+	if strings.Contains(r.pattern, "/") {
+		s = filepath.ToSlash(file.path)
+	}
+	ok, _ := filepath.Match(r.pattern, s)
+	return ok
+}
+
+// matchContentTypeRule returns the content type set by the first rule matching
+// the file, or an empty string if no rule matches.
+func matchContentTypeRule(file assetFile) string {
+	for _, r := range contentTypeRules {
+		if r.match(file) {
+			return r.contentType
+		}
+	}
+	return ""
+}
+
+// reportContentTypeRules logs the files matched by no rule, as well as the
+// files matched by several rules setting different content types.
+func reportContentTypeRules(files []assetFile) {
+	for _, f := range files {
+		var matched []contentTypeRule
+		for _, r := range contentTypeRules {
+			if r.match(f) {
+				matched = append(matched, r)
+			}
+		}
+
+		if len(matched) == 0 {
+			log.Printf("%s matches no content type rule, its content type will be detected.\n", f.path)
+			continue
+		}
+
+		for _, r := range matched[1:] {
+			if r.contentType != matched[0].contentType {
+				log.Printf("%s matches conflicting content type rules, using %s=%s over %s=%s.\n",
+					f.path, matched[0].pattern, matched[0].contentType, r.pattern, r.contentType)
+			}
+		}
+	}
+}
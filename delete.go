@@ -0,0 +1,32 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// deleteRelease deletes the release for tag, draft or not. A missing release is not an error, so that
+// cleanup scripts can be run again.
+func deleteRelease(tag string) error {
+	release, err := findReleaseByTag(tag)
+	if hasStatus(err, http.StatusNotFound) {
+		infof("There is no release for tag %s, nothing to delete.\n", tag)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/releases/%d", githubAPIEndpoint, release.ID)
+	if _, err := doRequest("DELETE", endpoint, "application/json", nil, int64(0)); err != nil {
+		return err
+	}
+	if !dryRunFlag {
+		infof("Deleted release %q (id %d) for tag %s.\n", release.Name, release.ID, tag)
+	}
+	return nil
+}
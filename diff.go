@@ -0,0 +1,82 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"text/tabwriter"
+)
+
+// releaseAssets returns the assets of the release for tag, indexed by name.
+func releaseAssets(tag string) (map[string]Asset, error) {
+	release, err := findReleaseByTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	assets, err := listAssets(release)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]Asset, len(assets))
+	for _, a := range assets {
+		byName[a.Name] = a
+	}
+	return byName, nil
+}
+
+// diffReleases prints the assets added, removed or changed in size between the
+// releases for tagA and tagB. It returns whether both releases have the same assets.
+func diffReleases(tagA, tagB string) bool {
+	assetsA, err := releaseAssets(tagA)
+	if err != nil {
+		fatalln(err)
+	}
+	assetsB, err := releaseAssets(tagB)
+	if err != nil {
+		fatalln(err)
+	}
+
+	names := make([]string, 0, len(assetsA)+len(assetsB))
+	for name := range assetsA {
+		names = append(names, name)
+	}
+	for name := range assetsB {
+		if _, ok := assetsA[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, " \tASSET\t%s\t%s\n", tagA, tagB)
+
+	same := true
+	for _, name := range names {
+		a, inA := assetsA[name]
+		b, inB := assetsB[name]
+		switch {
+		case !inB:
+			fmt.Fprintf(w, "-\t%s\t%d\t\n", name, a.Size)
+		case !inA:
+			fmt.Fprintf(w, "+\t%s\t\t%d\n", name, b.Size)
+		case a.Size != b.Size:
+			fmt.Fprintf(w, "~\t%s\t%d\t%d\n", name, a.Size, b.Size)
+		default:
+			continue
+		}
+		same = false
+	}
+
+	if same {
+		log.Printf("Releases %s and %s have the same assets.\n", tagA, tagB)
+		return true
+	}
+	w.Flush()
+	return false
+}
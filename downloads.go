@@ -0,0 +1,111 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+// Markers delimiting the downloads table in the release description, so that it
+// gets replaced rather than appended again on later runs.
+const (
+	downloadsStart = "<!-- github-release:downloads -->"
+	downloadsEnd   = "<!-- /github-release:downloads -->"
+)
+
+const defaultDownloadsTemplate = `### Downloads
+
+| File | Size |
+| ---- | ---- |
+{{range .Assets}}| [{{.Name}}]({{.BrowserDownloadURL}}) | {{humanSize .Size}} |
+{{end}}`
+
+var downloadsFuncs = template.FuncMap{"humanSize": humanSize}
+
+var downloadsTemplate = template.Must(template.New("downloads").Funcs(downloadsFuncs).Parse(defaultDownloadsTemplate))
+
+// loadDownloadsTemplate replaces the default downloads table template with the one in the file.
+func loadDownloadsTemplate(path string) error {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("downloads").Funcs(downloadsFuncs).Parse(string(text))
+	if err != nil {
+		return fmt.Errorf("Error: Invalid downloads template %s: %s", path, err)
+	}
+	downloadsTemplate = tmpl
+	return nil
+}
+
+// humanSize formats a number of bytes using binary units.
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// appendDownloadsTable adds a table of the release assets at the end of its description,
+// replacing the one added by a previous run if any.
+func appendDownloadsTable(release Release) error {
+	release, err := getRelease(release.ID)
+	if err != nil {
+		return err
+	}
+
+	assets, err := listAssets(release)
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		infof("Release has no assets, not adding a downloads table.\n")
+		return nil
+	}
+
+	var table bytes.Buffer
+	data := struct {
+		Release Release
+		Assets  []Asset
+	}{release, assets}
+	if err := downloadsTemplate.Execute(&table, data); err != nil {
+		return err
+	}
+
+	body := release.Body
+	if start := strings.Index(body, downloadsStart); start >= 0 {
+		if end := strings.Index(body[start:], downloadsEnd); end >= 0 {
+			body = body[:start] + body[start+end+len(downloadsEnd):]
+		}
+	}
+	body = strings.TrimRight(body, "\n")
+	if body != "" {
+		body += "\n\n"
+	}
+	body += downloadsStart + "\n" + strings.TrimRight(table.String(), "\n") + "\n" + downloadsEnd + "\n"
+
+	updateData, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return err
+	}
+
+	infof("Adding downloads table to the release description...\n")
+	endpoint := fmt.Sprintf("%s/releases/%d", githubAPIEndpoint, release.ID)
+	_, err = doRequest("PATCH", endpoint, "application/json", bytes.NewReader(updateData), int64(len(updateData)))
+	return err
+}
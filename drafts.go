@@ -0,0 +1,100 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseAge parses a duration like time.ParseDuration does, also accepting days, e.g. 30d.
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("Error: Invalid duration: %s", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Error: Invalid duration: %s", s)
+	}
+	return d, nil
+}
+
+// deleteOldDrafts deletes the draft releases created more than age ago, after confirmation.
+// Under -dry-run, they are only listed.
+func deleteOldDrafts(age time.Duration) error {
+	releases, err := listReleases()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-age)
+	var drafts []Release
+	for _, r := range releases {
+		if r.Draft && r.CreatedAt != nil && r.CreatedAt.Before(cutoff) {
+			drafts = append(drafts, r)
+		}
+	}
+
+	if len(drafts) == 0 {
+		log.Printf("No draft release older than %s.\n", age)
+		return nil
+	}
+
+	for _, r := range drafts {
+		log.Printf("Draft release %q (id %d, tag %s) was created on %s.\n", r.Name, r.ID, r.TagName, r.CreatedAt.Format(time.RFC3339))
+	}
+	if dryRunFlag {
+		log.Printf("Dry run, %d draft release(s) would be deleted.\n", len(drafts))
+		return nil
+	}
+
+	if !yesFlag {
+		ok, err := confirm(fmt.Sprintf("Delete %d draft release(s)?", len(drafts)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Println("Nothing was deleted.")
+			return nil
+		}
+	}
+
+	for _, r := range drafts {
+		endpoint := fmt.Sprintf("%s/releases/%d", githubAPIEndpoint, r.ID)
+		if _, err := doRequest("DELETE", endpoint, "application/json", nil, int64(0)); err != nil {
+			return err
+		}
+		log.Printf("Deleted draft release %q (id %d).\n", r.Name, r.ID)
+	}
+	return nil
+}
+
+// confirm asks the question on the terminal, returning whether the answer is yes.
+// Without a terminal to ask, -yes is required.
+func confirm(question string) (bool, error) {
+	stat, err := os.Stdin.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("Error: Not running in a terminal, give -yes to confirm")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
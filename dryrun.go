@@ -0,0 +1,123 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"archive/tar"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// printPlan logs what publishing the release with the given files would do, along with
+// the total size of the uploads and, given a bandwidth, an estimate of their duration.
+// Nothing is changed on Github.
+func printPlan(release Release, files []assetFile) error {
+	log.Println("Dry run, nothing is changed on Github.")
+	existing, err := planRelease(release)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, f := range files {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		size, err := fileSize(file)
+		var contentType string
+		if err == nil {
+			contentType, err = assetContentType(f, file, size)
+		}
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		action := "Would upload"
+		if asset, ok := existing[f.name]; ok {
+			if asset.Size == size && !replaceFlag {
+				log.Printf("Would skip %s, already uploaded as %s (%s)\n", f.path, f.name, humanSize(size))
+				continue
+			}
+			action = "Would replace"
+		}
+		log.Printf("%s %s as %s (%s, %s)\n", action, f.path, f.name, humanSize(size), contentType)
+		total += size
+	}
+
+	if fromTarFlag != "" {
+		err := walkTar(fromTarFlag, func(hdr *tar.Header, r io.Reader) error {
+			log.Printf("Would upload %s from %s (%s)\n", tarAssetName(hdr), fromTarFlag, humanSize(hdr.Size))
+			total += hdr.Size
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Total upload size: %s (%d bytes)\n", humanSize(total), total)
+
+	bandwidth := assumedBandwidthFlag
+	if maxUploadRateFlag > 0 && (bandwidth == 0 || maxUploadRateFlag < bandwidth) {
+		bandwidth = maxUploadRateFlag
+	}
+	if bandwidth > 0 {
+		estimate := time.Duration(float64(total) / float64(bandwidth) * float64(time.Second))
+		log.Printf("Estimated upload time: %s at %s/s\n", estimate.Round(time.Second), humanSize(bandwidth))
+	}
+	return nil
+}
+
+// planRelease logs which release would receive the files, returning the assets it already
+// has by name. The existing release is only looked up given a token, without which private
+// repositories look empty.
+func planRelease(release Release) (map[string]Asset, error) {
+	existing := make(map[string]Asset)
+	verb := "create or reuse"
+	if githubToken != "" {
+		reused, err := findReleaseByTag(release.TagName)
+		if err != nil && !hasStatus(err, http.StatusNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			assets, err := listAssets(reused)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range assets {
+				existing[a.Name] = a
+			}
+			log.Printf("Would reuse release %s (id %d), which has %d asset(s)\n", reused.TagName, reused.ID, len(assets))
+			return existing, nil
+		}
+		verb = "create"
+	}
+
+	log.Printf("Would %s release %s\n", verb, release.TagName)
+	log.Printf("  name:       %s\n", release.Name)
+	if release.Branch != "" {
+		log.Printf("  target:     %s\n", release.Branch)
+	}
+	log.Printf("  draft:      %t\n", release.Draft)
+	log.Printf("  prerelease: %t\n", release.Prerelease)
+	if release.GenerateNotes {
+		log.Println("  notes:      generated by Github")
+	}
+	if release.DiscussionCategory != "" {
+		log.Printf("  discussion: %s\n", release.DiscussionCategory)
+	}
+	if release.MakeLatest != "" {
+		log.Printf("  latest:     %s\n", release.MakeLatest)
+	}
+	if tagMessageFlag != "" {
+		log.Printf("  tag:        annotated, unless %s exists\n", release.TagName)
+	}
+	return existing, nil
+}
@@ -0,0 +1,110 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// setReleaseFlags flips the prerelease and/or draft state of the release for tag as
+// requested by -set-prerelease and -set-draft, leaving everything else untouched.
+func setReleaseFlags(tag string) {
+	var prerelease, draft bool
+	if setPrereleaseFlag != "" {
+		prerelease = parseBoolFlag("set-prerelease", setPrereleaseFlag)
+	}
+	if setDraftFlag != "" {
+		draft = parseBoolFlag("set-draft", setDraftFlag)
+	}
+
+	release, err := findReleaseByTag(tag)
+	if err != nil {
+		fatalln(err)
+	}
+
+	update := make(map[string]interface{})
+	if setPrereleaseFlag != "" {
+		if release.Prerelease == prerelease {
+			log.Printf("Release %s already has prerelease set to %t, nothing to do.\n", tag, prerelease)
+		} else {
+			update["prerelease"] = prerelease
+		}
+	}
+
+	if setDraftFlag != "" {
+		if release.Draft == draft {
+			log.Printf("Release %s already has draft set to %t, nothing to do.\n", tag, draft)
+		} else {
+			update["draft"] = draft
+		}
+	}
+
+	if len(update) == 0 {
+		return
+	}
+
+	updateData, err := json.Marshal(update)
+	if err != nil {
+		fatalln(err)
+	}
+
+	infof("Updating release %s...\n", tag)
+	endpoint := fmt.Sprintf("%s/releases/%d", githubAPIEndpoint, release.ID)
+	_, err = doRequest("PATCH", endpoint, "application/json", bytes.NewBuffer(updateData), int64(len(updateData)))
+	if err != nil {
+		fatalln(err)
+	}
+}
+
+// editRelease updates the description of the existing release for tag, along with its
+// name under -name and its state under -draft or -prerelease, leaving its assets untouched.
+func editRelease(tag, desc string) {
+	release, err := findReleaseByTag(tag)
+	if hasStatus(err, http.StatusNotFound) {
+		fatalf("Error: There is no release for tag %s to edit\n", tag)
+	}
+	if err != nil {
+		fatalln(err)
+	}
+
+	update := map[string]interface{}{"body": desc}
+	if nameFlag != "" {
+		update["name"] = nameFlag
+	}
+	if isFlagSet("draft") {
+		update["draft"] = draftFlag
+	}
+	if isFlagSet("prerelease") {
+		update["prerelease"] = prereleaseFlag
+	}
+	if makeLatestFlag != "" {
+		update["make_latest"] = makeLatestFlag
+	}
+
+	updateData, err := json.Marshal(update)
+	if err != nil {
+		fatalln(err)
+	}
+
+	infof("Editing release %s...\n", tag)
+	endpoint := fmt.Sprintf("%s/releases/%d", githubAPIEndpoint, release.ID)
+	_, err = doRequest("PATCH", endpoint, "application/json", bytes.NewBuffer(updateData), int64(len(updateData)))
+	if err != nil {
+		fatalln(err)
+	}
+}
+
+func parseBoolFlag(name, value string) bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		fatalf("Error: Invalid -%s value: %q, true or false is expected\n", name, value)
+	}
+	return b
+}
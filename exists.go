@@ -0,0 +1,39 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// exitNotFound is the exit status of -exists when there is no release for the tag.
+const exitNotFound = 10
+
+// releaseExists looks up the release for tag, drafts included, returning the exit status of -exists.
+func releaseExists(tag string) int {
+	_, err := findReleaseByTag(tag)
+	if err != nil && !hasStatus(err, http.StatusNotFound) {
+		log.Println(err)
+		return 1
+	}
+
+	exists := err == nil
+	if jsonFlag {
+		if err := printJSON(map[string]bool{"exists": exists}); err != nil {
+			log.Println(err)
+			return 1
+		}
+	} else if exists {
+		log.Printf("Release %s exists.\n", tag)
+	} else {
+		log.Printf("Release %s does not exist.\n", tag)
+	}
+
+	if !exists {
+		return exitNotFound
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// downloadRelease downloads into dir the assets of the release for tag whose names match
+// the glob pattern, or all of them if it is empty. Existing files are overwritten.
+func downloadRelease(tag, dir, pattern string) error {
+	if pattern != "" {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Error: Invalid glob pattern: %s", pattern)
+		}
+	}
+
+	release, err := findReleaseByTag(tag)
+	if hasStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("Error: There is no release for tag %s", tag)
+	}
+	if err != nil {
+		return err
+	}
+
+	assets, err := listAssets(release)
+	if err != nil {
+		return err
+	}
+
+	var matching []Asset
+	for _, a := range assets {
+		if ok, _ := path.Match(pattern, a.Name); ok || pattern == "" {
+			matching = append(matching, a)
+		}
+	}
+	if len(matching) == 0 {
+		return fmt.Errorf("Error: Release %s has no asset matching %q", tag, pattern)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, a := range matching {
+		infof("Downloading %s (%s)...\n", a.Name, humanSize(a.Size))
+		if err := downloadAssetFile(a, filepath.Join(dir, filepath.Base(a.Name))); err != nil {
+			return fmt.Errorf("Error: Unable to download %s: %s", a.Name, err)
+		}
+	}
+	infof("Downloaded %d asset(s) of release %s to %s.\n", len(matching), tag, dir)
+	return nil
+}
+
+// downloadAssetFile downloads the asset to a temporary file next to dest, only renamed to
+// dest once complete so that a failed download doesn't leave a truncated file behind.
+func downloadAssetFile(asset Asset, dest string) error {
+	partial := dest + ".part"
+	file, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+
+	err = downloadAsset(asset, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && localSize(partial) != asset.Size {
+		err = fmt.Errorf("downloaded %d bytes instead of %d", localSize(partial), asset.Size)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, dest)
+}
@@ -0,0 +1,76 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// recursiveGlob returns the regular files matching a glob pattern in which ** matches any
+// number of directories, e.g. dist/**/*.tar.gz, which filepath.Glob does not support.
+// Only malformed patterns are reported as errors.
+func recursiveGlob(pattern string) ([]string, error) {
+	// The walk yields clean paths, e.g. dist/a.tgz for ./dist/a.tgz, so the pattern has to be clean too.
+	segments := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	for _, s := range segments {
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only walk the directory the pattern starts from.
+	literal := 0
+	for literal < len(segments) && !hasMeta(segments[literal]) {
+		literal++
+	}
+	root := strings.Join(segments[:literal], "/")
+	if root == "" && literal > 0 {
+		root = "/"
+	} else if root == "" {
+		root = "."
+	}
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(p string, d fs.DirEntry, err error) error {
+		// Like filepath.Glob, ignore what cannot be read.
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() && matchSegments(segments, strings.Split(filepath.ToSlash(p), "/")) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// hasMeta tells whether a path segment holds glob special characters.
+func hasMeta(segment string) bool {
+	return strings.ContainsAny(segment, `*?[\`)
+}
+
+// matchSegments matches the segments of a path against the ones of a pattern, where a **
+// segment matches any number of path segments, none included.
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,78 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRecursiveGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"dist/a/x.tgz", "dist/a/b/y.tgz", "dist/z.tgz", "dist/notes.txt", "other/w.tgz"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tgz := []string{"dist/a/b/y.tgz", "dist/a/x.tgz", "dist/z.tgz"}
+	all := []string{"dist/a/b/y.tgz", "dist/a/x.tgz", "dist/notes.txt", "dist/z.tgz"}
+	abs := func(names []string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.ToSlash(filepath.Join(dir, name)))
+		}
+		return paths
+	}
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{"dist/**/*.tgz", tgz},
+		{"./dist/**/*.tgz", tgz},
+		{"dist/./**/*.tgz", tgz},
+		{filepath.ToSlash(dir) + "/dist/**/*.tgz", abs(tgz)},
+		{"dist/**", all},
+		{"./dist/**", all},
+		{"dist/a/**", []string{"dist/a/b/y.tgz", "dist/a/x.tgz"}},
+		{"**/w.tgz", []string{"other/w.tgz"}},
+		{"dist/**/*.zip", nil},
+		{"missing/**/*.tgz", nil},
+	}
+	for _, c := range cases {
+		got, err := recursiveGlob(c.pattern)
+		if err != nil {
+			t.Errorf("recursiveGlob(%q) failed: %s", c.pattern, err)
+			continue
+		}
+		for i := range got {
+			got[i] = filepath.ToSlash(got[i])
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("recursiveGlob(%q) = %q, want %q", c.pattern, got, c.want)
+		}
+	}
+
+	if _, err := recursiveGlob("dist/**/[.tgz"); err == nil {
+		t.Error("recursiveGlob() accepted a malformed pattern")
+	}
+}
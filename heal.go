@@ -0,0 +1,81 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"log"
+)
+
+// healRelease makes the assets of the existing release for tag match the files, uploading
+// only the missing ones and the ones with the wrong size, then checks the result.
+// It returns whether the release ends up in sync with the files.
+func healRelease(tag string, files []assetFile) bool {
+	release, err := findReleaseByTag(tag)
+	if err != nil {
+		fatalln(err)
+	}
+
+	broken, err := brokenAssets(release, files)
+	if err != nil {
+		fatalln(err)
+	}
+	if len(broken) == 0 {
+		infof("Release %s is healthy, nothing to do.\n", tag)
+		return true
+	}
+
+	var toUpload []assetFile
+	for _, b := range broken {
+		infof("%s: %s\n", b.file.name, b.reason)
+		toUpload = append(toUpload, b.file)
+	}
+
+	// A failed upload must not stop the run before the release is checked again.
+	continueFlag = true
+	if err := uploadFiles(release, toUpload); err != nil {
+		log.Println(err)
+	}
+
+	invalidateAssets(release)
+	broken, err = brokenAssets(release, files)
+	if err != nil {
+		fatalln(err)
+	}
+	for _, b := range broken {
+		log.Printf("Error: %s is still broken: %s\n", b.file.name, b.reason)
+	}
+	if len(broken) > 0 {
+		return false
+	}
+
+	infof("Healed %d asset(s) of release %s.\n", len(toUpload), tag)
+	return true
+}
+
+// brokenAsset is a file whose asset is missing from the release or is not right.
+type brokenAsset struct {
+	file   assetFile
+	reason string
+}
+
+// brokenAssets returns the files whose asset is missing from the release or has the wrong size.
+func brokenAssets(release Release, files []assetFile) ([]brokenAsset, error) {
+	var broken []brokenAsset
+	for _, f := range files {
+		asset, err := getAssetByFilename(release, f.name)
+		if err == errAssetNotFound {
+			broken = append(broken, brokenAsset{f, "missing"})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if asset.Size != localSize(f.path) {
+			broken = append(broken, brokenAsset{f, "wrong size"})
+		}
+	}
+	return broken, nil
+}
@@ -0,0 +1,67 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"text/tabwriter"
+)
+
+// listAll prints every release of the repository along with its assets, as a table or,
+// under -json, as the array of releases returned by Github.
+func listAll() {
+	if jsonFlag {
+		releases, err := lookupReleases()
+		if err != nil {
+			fatalln(err)
+		}
+		if err := printJSON(releases); err != nil {
+			fatalln(err)
+		}
+		return
+	}
+
+	releases, err := listReleases()
+	if err != nil {
+		fatalln(err)
+	}
+	if len(releases) == 0 {
+		log.Println("There are no releases.")
+		return
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tSTATE\tASSET\tSIZE")
+	for _, r := range releases {
+		assets, err := listAssets(r)
+		if err != nil {
+			fatalln(err)
+		}
+
+		tag, state := r.TagName, releaseState(r)
+		if len(assets) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t\t\n", tag, state)
+		}
+		for _, a := range assets {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", tag, state, a.Name, a.Size)
+			tag, state = "", ""
+		}
+	}
+	w.Flush()
+}
+
+// releaseState describes whether the release is a draft, a prerelease, or published.
+func releaseState(release Release) string {
+	switch {
+	case release.Draft && release.Prerelease:
+		return "draft prerelease"
+	case release.Draft:
+		return "draft"
+	case release.Prerelease:
+		return "prerelease"
+	}
+	return "published"
+}
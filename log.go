@@ -0,0 +1,31 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"log"
+)
+
+// Levels of the messages logged. Progress messages are informational, while errors,
+// even recovered from, are always logged.
+const (
+	levelInfo = iota
+	levelError
+)
+
+// logLevel is the level below which messages are not logged, raised by -quiet.
+var logLevel = levelInfo
+
+// logf logs a message of the given level.
+func logf(level int, format string, args ...interface{}) {
+	if level >= logLevel {
+		log.Printf(format, args...)
+	}
+}
+
+// infof logs a progress message, unless under -quiet.
+func infof(format string, args ...interface{}) {
+	logf(levelInfo, format, args...)
+}
@@ -7,18 +7,25 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -26,28 +33,146 @@ var (
 	githubUser        string
 	githubRepo        string
 	githubAPIEndpoint string
+	// githubAPIRoot is the Github API endpoint, before githubAPIEndpoint gets scoped to the repository.
+	githubAPIRoot string
 	// Version gets initialized in compilation time.
 	Version string
 	debug   bool
+	// httpClient is used for every request sent to Github. Its timeout is set by -timeout.
+	httpClient = &http.Client{}
+	// uploadSlots bounds the number of uploads in progress to -upload-concurrency.
+	uploadSlots chan struct{}
 )
 
 // Release represents a Github Release.
 type Release struct {
-	UploadURL  string `json:"upload_url,omitempty"`
-	TagName    string `json:"tag_name"`
-	Branch     string `json:"target_commitish"`
-	Name       string `json:"name"`
-	Body       string `json:"body"`
-	Draft      bool   `json:"draft"`
-	Prerelease bool   `json:"prerelease"`
+	ID            int64  `json:"id,omitempty"`
+	UploadURL     string `json:"upload_url,omitempty"`
+	TagName       string `json:"tag_name"`
+	Branch        string `json:"target_commitish,omitempty"`
+	Name          string `json:"name"`
+	Body          string `json:"body"`
+	Draft         bool   `json:"draft"`
+	Prerelease    bool   `json:"prerelease"`
+	GenerateNotes bool   `json:"generate_notes,omitempty"`
+
+	DiscussionCategory string `json:"discussion_category_name,omitempty"`
+	MakeLatest         string `json:"make_latest,omitempty"`
+
+	HTMLURL       string     `json:"html_url,omitempty"`
+	DiscussionURL string     `json:"discussion_url,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	Assets        []Asset    `json:"assets,omitempty"`
+}
+
+// Asset represents a Github Release asset.
+type Asset struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Label              string `json:"label"`
+	ContentType        string `json:"content_type"`
+	State              string `json:"state"`
+	Size               int64  `json:"size"`
+	URL                string `json:"url"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	Digest             string `json:"digest,omitempty"`
 }
 
 var verFlag bool
 var prereleaseFlag bool
 var draftFlag bool
+var draftNameFlag string
+var sniffBytesFlag int
+var noReuseFlag bool
+var fromTarFlag string
+var checkFlag bool
+var retriesFlag int
+var createRetriesFlag int
+var uploadRetriesFlag int
+var metadataRetriesFlag int
+var timeoutFlag time.Duration
+var concurrencyFlag int
+var parallelFlag int
+var setPrereleaseFlag string
+var setDraftFlag string
+var contentTypeRulesFlag string
+var contentTypeFlag string
+var apiVersionFlag string
+var tagMessageFlag string
+var bodyTemplateFlag bool
+var tokenFileFlag string
+var waitForAssetFlag time.Duration
+var checksumsNameFlag string
+var renameFlags renameList
+var verboseFlag bool
+var maxAssetSizeFlag int64
+var eventsFileFlag string
+var existsFlag string
+var jsonFlag bool
+var replaceFlag bool
+var uploadConcurrencyFlag int
+var settleFlag time.Duration
+var idFileFlag string
+var checksumsFlag bool
+var hashFlag string
+var manifestFormatFlag string
+var manifestDirFlag string
+var diffFlag bool
+var diffExitCodeFlag int
+var resumeFlag bool
+var connectTimeoutFlag time.Duration
+var ownerTypeFlag string
+var assetFlags assetFileList
+var strictContentLengthFlag bool
+var appendDownloadsTableFlag bool
+var downloadsTemplateFlag string
+var verifyTargetFlag bool
+var timingsFlag bool
+var noAssetVerifyFlag bool
+var maxUploadRateFlag int64
+var refreshSignaturesFlag bool
+var bodyFromTagFlag bool
+var preserveDraftFlag bool
+var stepSummaryFlag bool
+var failFastFlag bool
+var continueFlag bool
+var verifyEtagFlag bool
+var targetFileFlag string
+var targetFlag string
+var allowRedirectHostFlag string
+var assetsFromFlag string
+var dryRunFlag bool
+var assumedBandwidthFlag int64
+var autoLabelPlatformFlag bool
+var platformRulesFlag string
+var checksumLabelFlag bool
+var deleteDraftsFlag string
+var yesFlag bool
+var tmpDirFlag string
+var skipIfExistsFlag bool
+var bodyStdinFlag bool
+var bodyTrimFlag bool
+var bodyFileFlag string
+var nameFlag string
+var healFlag bool
+var deleteFlag string
+var exportEnvFlag bool
+var verifyChecksumFlag bool
+var quietFlag bool
+var uploadAPIFlag string
+var editFlag bool
+var assetNameFlag string
+var listFlag bool
+var configFlag string
+var generateNotesFlag bool
+var discussionCategoryFlag string
+var makeLatestFlag string
+var strictFilesFlag bool
+var downloadFlag string
 
 func init() {
 	log.SetFlags(0)
+	log.SetOutput(os.Stderr)
 
 	debug, _ = strconv.ParseBool(os.Getenv("DEBUG"))
 
@@ -60,36 +185,436 @@ func init() {
 		githubAPIEndpoint = "https://api.github.com"
 	}
 
+	// Environment variables only provide defaults, flags take precedence.
+	retries := envInt("GITHUB_RELEASE_RETRIES", 5)
+	timeout := envDuration("GITHUB_RELEASE_TIMEOUT", envDuration("GITHUB_HTTP_TIMEOUT", 10*time.Minute))
+	concurrency := envInt("GITHUB_RELEASE_CONCURRENCY", 1)
+
 	flag.BoolVar(&verFlag, "version", false, "-version")
 	flag.BoolVar(&prereleaseFlag, "prerelease", false, "-prerelease")
 	flag.BoolVar(&draftFlag, "draft", false, "-draft")
-	flag.Parse()
+	flag.StringVar(&draftNameFlag, "draft-name", "", "-draft-name <name>")
+	flag.IntVar(&sniffBytesFlag, "sniff-bytes", 512, "-sniff-bytes N")
+	flag.BoolVar(&noReuseFlag, "no-reuse", false, "-no-reuse")
+	flag.StringVar(&fromTarFlag, "from-tar", "", "-from-tar <file>")
+	flag.BoolVar(&checkFlag, "check", false, "-check")
+	flag.IntVar(&retriesFlag, "retries", retries, "-retries N")
+	flag.IntVar(&createRetriesFlag, "create-retries", 0, "-create-retries N")
+	flag.IntVar(&uploadRetriesFlag, "upload-retries", 0, "-upload-retries N")
+	flag.IntVar(&metadataRetriesFlag, "metadata-retries", 0, "-metadata-retries N")
+	flag.DurationVar(&timeoutFlag, "timeout", timeout, "-timeout <duration>")
+	flag.IntVar(&concurrencyFlag, "concurrency", concurrency, "-concurrency N")
+	flag.IntVar(&parallelFlag, "parallel", 1, "-parallel N")
+	flag.StringVar(&setPrereleaseFlag, "set-prerelease", "", "-set-prerelease true|false")
+	flag.StringVar(&setDraftFlag, "set-draft", "", "-set-draft true|false")
+	flag.StringVar(&contentTypeRulesFlag, "content-type-rules", "", "-content-type-rules <file>")
+	flag.StringVar(&contentTypeFlag, "content-type", "", "-content-type <type>")
+	flag.StringVar(&apiVersionFlag, "api-version", defaultAPIVersion, "-api-version <version>")
+	flag.StringVar(&tagMessageFlag, "tag-message", "", "-tag-message <message>")
+	flag.BoolVar(&bodyTemplateFlag, "body-template", false, "-body-template")
+	flag.StringVar(&tokenFileFlag, "token-file", os.Getenv("GITHUB_TOKEN_FILE"), "-token-file <file>")
+	flag.DurationVar(&waitForAssetFlag, "wait-for-asset", 0, "-wait-for-asset <duration>")
+	flag.StringVar(&checksumsNameFlag, "checksums-name", "", "-checksums-name <name>")
+	flag.Var(&renameFlags, "rename", "-rename <file>=<name>")
+	flag.BoolVar(&verboseFlag, "verbose", false, "-verbose")
+	flag.Int64Var(&maxAssetSizeFlag, "max-github-asset-size", defaultMaxAssetSize, "-max-github-asset-size <bytes>")
+	flag.StringVar(&eventsFileFlag, "events-file", "", "-events-file <file>")
+	flag.StringVar(&existsFlag, "exists", "", "-exists <tag>")
+	flag.BoolVar(&jsonFlag, "json", false, "-json")
+	flag.BoolVar(&replaceFlag, "replace", false, "-replace")
+	flag.BoolVar(&replaceFlag, "clobber", false, "-clobber")
+	flag.IntVar(&uploadConcurrencyFlag, "upload-concurrency", 0, "-upload-concurrency N")
+	flag.DurationVar(&settleFlag, "settle", 0, "-settle <duration>")
+	flag.StringVar(&idFileFlag, "id-file", "", "-id-file <path>")
+	flag.BoolVar(&checksumsFlag, "checksums", false, "-checksums")
+	flag.StringVar(&hashFlag, "hash", "sha256", "-hash sha256,sha512")
+	flag.StringVar(&manifestFormatFlag, "manifest-format", "sums", "-manifest-format sums|json|csv")
+	flag.StringVar(&manifestDirFlag, "manifest-dir", "", "-manifest-dir <dir>")
+	flag.BoolVar(&diffFlag, "diff", false, "-diff")
+	flag.IntVar(&diffExitCodeFlag, "diff-exit-code", 1, "-diff-exit-code N")
+	flag.BoolVar(&resumeFlag, "resume", false, "-resume")
+	flag.DurationVar(&connectTimeoutFlag, "connect-timeout", 10*time.Second, "-connect-timeout <duration>")
+	flag.StringVar(&ownerTypeFlag, "owner-type", "", "-owner-type org|user")
+	flag.Var(&assetFlags, "asset", "-asset name:/path/to/file")
+	flag.BoolVar(&strictContentLengthFlag, "strict-content-length", false, "-strict-content-length")
+	flag.BoolVar(&appendDownloadsTableFlag, "append-downloads-table", false, "-append-downloads-table")
+	flag.StringVar(&downloadsTemplateFlag, "downloads-template", "", "-downloads-template <file>")
+	flag.BoolVar(&verifyTargetFlag, "verify-target", false, "-verify-target")
+	flag.BoolVar(&timingsFlag, "timings", false, "-timings")
+	flag.BoolVar(&noAssetVerifyFlag, "no-asset-verify", false, "-no-asset-verify")
+	flag.Int64Var(&maxUploadRateFlag, "max-upload-rate", 0, "-max-upload-rate <bytes/sec>")
+	flag.BoolVar(&refreshSignaturesFlag, "refresh-signatures", false, "-refresh-signatures")
+	flag.BoolVar(&bodyFromTagFlag, "body-from-tag", false, "-body-from-tag")
+	flag.BoolVar(&preserveDraftFlag, "preserve-draft-if-exists", false, "-preserve-draft-if-exists")
+	flag.BoolVar(&stepSummaryFlag, "step-summary", false, "-step-summary")
+	flag.BoolVar(&failFastFlag, "fail-fast", true, "-fail-fast")
+	flag.BoolVar(&continueFlag, "continue", false, "-continue")
+	flag.BoolVar(&verifyEtagFlag, "verify-etag", false, "-verify-etag")
+	flag.StringVar(&targetFileFlag, "target-file", "", "-target-file <path>")
+	flag.StringVar(&targetFlag, "target", "", "-target <branch>")
+	flag.StringVar(&allowRedirectHostFlag, "allow-redirect-host", "", "-allow-redirect-host <host,...>")
+	flag.StringVar(&assetsFromFlag, "assets-from", "", "-assets-from <file>")
+	flag.BoolVar(&dryRunFlag, "dry-run", false, "-dry-run")
+	flag.Int64Var(&assumedBandwidthFlag, "assumed-bandwidth", 0, "-assumed-bandwidth <bytes/sec>")
+	flag.BoolVar(&autoLabelPlatformFlag, "auto-label-platform", false, "-auto-label-platform")
+	flag.StringVar(&platformRulesFlag, "platform-rules", "", "-platform-rules <file>")
+	flag.BoolVar(&checksumLabelFlag, "checksum-label", false, "-checksum-label")
+	flag.StringVar(&deleteDraftsFlag, "delete-drafts-older-than", "", "-delete-drafts-older-than <duration>")
+	flag.BoolVar(&yesFlag, "yes", false, "-yes")
+	flag.StringVar(&tmpDirFlag, "tmp-dir", "", "-tmp-dir <path>")
+	flag.BoolVar(&skipIfExistsFlag, "skip-if-exists", false, "-skip-if-exists")
+	flag.BoolVar(&bodyStdinFlag, "body-stdin", false, "-body-stdin")
+	flag.BoolVar(&bodyTrimFlag, "body-trim", false, "-body-trim")
+	flag.StringVar(&bodyFileFlag, "body-file", "", "-body-file <path>")
+	flag.StringVar(&nameFlag, "name", "", "-name <title>")
+	flag.BoolVar(&healFlag, "heal", false, "-heal")
+	flag.StringVar(&deleteFlag, "delete", "", "-delete <tag>")
+	flag.BoolVar(&exportEnvFlag, "export-env", false, "-export-env")
+	flag.BoolVar(&verifyChecksumFlag, "verify-checksum", false, "-verify-checksum")
+	flag.BoolVar(&quietFlag, "quiet", false, "-quiet")
+	flag.StringVar(&uploadAPIFlag, "upload-url", os.Getenv("GITHUB_UPLOAD_API"), "-upload-url <url>")
+	flag.BoolVar(&editFlag, "edit", false, "-edit")
+	flag.StringVar(&assetNameFlag, "asset-name", "", "-asset-name <name>")
+	flag.BoolVar(&listFlag, "list", false, "-list")
+	flag.StringVar(&configFlag, "config", "", "-config <file>")
+	flag.BoolVar(&generateNotesFlag, "generate-notes", false, "-generate-notes")
+	flag.StringVar(&discussionCategoryFlag, "discussion-category", "", "-discussion-category <name>")
+	flag.StringVar(&makeLatestFlag, "make-latest", "", "-make-latest true|false|legacy")
+	flag.BoolVar(&strictFilesFlag, "strict-files", false, "-strict-files")
+	flag.StringVar(&downloadFlag, "download", "", "-download <dir>")
+}
+
+// newTransport returns a transport like http.DefaultTransport whose connections have
+// to be established within connectTimeout.
+func newTransport(connectTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	return transport
+}
+
+// checkRedirect only lets the client follow redirects to the hosts allowed by
+// -allow-redirect-host, if any, e.g. the storage asset downloads are redirected to.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if allowRedirectHostFlag == "" {
+		return nil
+	}
+
+	host := req.URL.Hostname()
+	for _, pattern := range strings.Split(allowRedirectHostFlag, ",") {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), host); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("Error: Redirect to %s refused, its host is not allowed by -allow-redirect-host", req.URL.Host)
+}
+
+// isFlagSet tells whether the flag with the given name was given on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// readTokenFile returns the token held by a file, such as a mounted secret, without the
+// surrounding whitespace.
+func readTokenFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error: Unable to read the token: %s", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("Error: Token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// envInt returns the non-negative integer held by an environment variable, or def if it is not set.
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		fatalf("Error: Invalid value for %s: %q, a non-negative integer is expected\n", name, value)
+	}
+	return n
+}
+
+// envDuration returns the duration held by an environment variable, or def if it is not set.
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		fatalf("Error: Invalid value for %s: %q, a duration such as 90s or 10m is expected\n", name, value)
+	}
+	return d
 }
 
 var usage = `Github command line release tool.
 
 Usage:
-	github-release <user/repo> <tag> <branch> <description> "<files>"
+	github-release <user/repo> <tag> <branch> <description> "<files>" ["<files>"...]
+	github-release [-target <branch>] <user/repo> <tag> <description> "<files>" ["<files>"...]
+	github-release -check <user/repo> <tag> "<files>"
+	github-release -set-prerelease true|false -set-draft true|false <user/repo> <tag>
+	github-release -exists <tag> <user/repo>
+	github-release -diff <user/repo> <tagA> <tagB>
+	github-release -refresh-signatures <user/repo> <tag> "<files>"
+	github-release -delete-drafts-older-than <duration> <user/repo>
+	github-release -heal <user/repo> <tag> "<files>"
+	github-release -delete <tag> <user/repo>
+	github-release -edit <user/repo> <tag> <description>
+	github-release -list <user/repo>
+	github-release -download <dir> <user/repo> <tag> ["<pattern>"]
 
 Parameters:
 	<user/repo>: Github user and repository
 	<tag>: Used to created the release. It is also used as the release's name
-	<branch>: Reference from where to create the provided <tag>, if it does not exist
+	<branch>: Reference from where to create the provided <tag>, if it does not exist: a branch, or a full
+	or abbreviated commit SHA, the latter being expanded. It is ignored when <tag> already exists, and can
+	be left out, in which case Github uses the default branch
 	<description>: The release description
 	<files>: Glob pattern describing the list of files to include in the release.
 	Make sure you enclose it in quotes to avoid the shell expanding the glob pattern.
+	It can end with #<label> to label the matching assets, e.g. "dist/app-linux#Linux x86_64 binary"
+	Use - to upload stdin instead, as the asset named by -asset-name. ** matches any number of
+	directories, e.g. "dist/**/*.tar.gz"
+	Several patterns can be given, e.g. "dist/*.tar.gz" "checksums/*.txt", to upload the files matching any
+	of them. <branch> then has to be given, possibly as "", unless -target is
 
 Options:
 	-version: Displays version
-	-prerelease: Identify the release as a prerelease
-	-draft: Save as draft, don't publish
+	-prerelease: Identify the release as a prerelease. Turned off by -prerelease=false, e.g. under -edit
+	-draft: Save as draft, don't publish. Turned off by -draft=false, e.g. under -edit
+	-draft-name <name>: Attach files to the existing draft release with the given name instead of
+	creating a new release. Unless -draft is also given, the draft is then published using <tag> and <branch>
+	-sniff-bytes N: Number of bytes read from each file to detect its content type. Defaults to 512.
+	Formats such as ISO images are only recognized with a larger value, e.g. 33000
+	-no-reuse: Fail if a release for <tag> already exists instead of attaching the files to it.
+	The existing release is left untouched. Cannot be combined with -draft-name or -edit
+	-from-tar <file>: Also upload every regular file inside the given tar archive as a separate asset,
+	without extracting it to disk. Archives ending in .gz or .tgz are decompressed on the fly.
+	Entries are named after their base name; colliding names are an error
+	-check: Compare the assets of the existing release for <tag> with the local "<files>" and exit
+	with a non-zero status, listing the differences, if they are out of sync. Names and sizes are
+	compared, as well as SHA-256 checksums when the release has a SHA256SUMS asset. Nothing is modified
+	-retries N: Number of times a failed request is retried, with exponential backoff. Defaults to 5
+	-create-retries N, -upload-retries N, -metadata-retries N: Number of retries for, respectively, the
+	creation of the release, each asset upload, and the lookups of releases and assets. Each defaults to
+	-retries. There is no overall time limit: every attempt is bounded by -timeout, and the backoff
+	between attempts doubles from 1s. When Github rate limits a request, it is retried after the delay
+	given by its Retry-After or X-RateLimit-Reset header instead
+	-timeout <duration>: Time limit for each request sent to Github, uploads included, e.g. 30m. 0 means no
+	limit. Defaults to 10m, which may be too short for big assets on slow links or under -max-upload-rate
+	-connect-timeout <duration>: Time limit for establishing connections to Github, so that an unreachable
+	endpoint fails fast regardless of -timeout. Defaults to 10s
+	-concurrency N: Number of files uploaded at the same time. Defaults to 1
+	-parallel N: Same as -concurrency, except that failed uploads are reported together at the end, as
+	with -continue, unless -fail-fast is given. The messages of each upload are prefixed with its file name
+	-set-prerelease true|false: Only mark or unmark the existing release for <tag> as a prerelease.
+	Nothing else about the release, including its assets, is changed
+	-set-draft true|false: Same as -set-prerelease, for the draft state of the release
+	-content-type-rules <file>: File of glob=content/type lines, e.g. *.tar.gz=application/gzip, setting
+	the content type of the files they match. The first matching rule wins, files matching no rule get
+	a detected content type. Patterns without a slash are matched against file names only
+	-verbose: Print additional details, such as files matched by conflicting content type rules
+	or the raw Github errors behind friendlier messages
+	-max-github-asset-size <bytes>: Refuse to start if a file is larger than this, as Github would reject it.
+	Defaults to Github's limit of 2 GiB. Set it to your Github Enterprise's limit if different, or 0 to disable the check
+	-events-file <file>: Append the result of each asset upload to the file, as soon as it is known, as a line of JSON
+	such as {"asset":"app.tar.gz","status":"uploaded","size":123,"ms":4210}. Use - to write them to stdout
+	-exists <tag>: Only check whether a release, draft or not, exists for <tag>. Exits with 0 if it does, 10 if it
+	does not, and 1 on any other error
+	-json: Print results as JSON on stdout. Once the release is published, it is printed with its id,
+	html_url, upload_url and assets. -exists prints {"exists":true} or {"exists":false}
+	-replace: Delete and upload again the assets that already exist, even if their size is right.
+	The label of a replaced asset is kept
+	-clobber: Same as -replace
+	-upload-concurrency N: Maximum number of uploads in progress at the same time, independently of
+	-concurrency which also bounds the API calls made around uploads. Defaults to the value of -concurrency
+	-settle <duration>: Time to wait after an upload before checking the size Github reports for the asset.
+	Helps with backends that are slow to report it, which otherwise causes needless uploads. Defaults to 0
+	-id-file <path>: Write the numeric ID of the release, whether created or reused, to the given file
+	-checksums: Generate a checksums manifest of the files and upload it along with them
+	-hash sha256,sha512: Hash algorithms used by -checksums, one manifest being generated per algorithm,
+	e.g. SHA256SUMS and SHA512SUMS. Supported algorithms are sha1, sha256 and sha512. Defaults to sha256
+	-manifest-format sums|json|csv: Format of the manifests generated by -checksums. sums is the format
+	of sha256sum and friends, json an array of {"name","sha256","size"} objects, and csv a table with the
+	same columns. json and csv manifests get a .json or .csv extension. Defaults to sums
+	-manifest-dir <dir>: Keep the manifests generated by -checksums in the given directory, instead of
+	a temporary one removed once done
+	-diff: Compare the assets of the releases for <tagA> and <tagB>, listing the assets only found in one
+	of them and the ones whose size differs. Nothing is modified
+	-diff-exit-code N: Exit status of -diff when the releases differ. Defaults to 1
+	-resume: List the assets of the release once before uploading, and skip right away the files already
+	uploaded with the right size. Makes re-running an interrupted upload of many files faster
+	-owner-type org|user: Whether the repository belongs to an organization or a user, used to give
+	better advice on permission errors. Detected when not given
+	-asset name:/path/to/file: Also upload the given file as an asset with the given name. Can be repeated.
+	Asset names have to be unique among these files and the ones matching "<files>"
+	-strict-content-length: Fail instead of retrying when an asset still doesn't have the size of its file
+	after being uploaded again once, which points at something systematically altering uploads
+	-append-downloads-table: Once files are uploaded, append to the release description a Markdown table
+	of its assets with their sizes and download links. Running again replaces the table
+	-downloads-template <file>: Go template used by -append-downloads-table instead of the default table.
+	It is given the release as .Release and its assets as .Assets, and can format sizes with humanSize
+	-verify-target: Make sure <branch> resolves to a commit before creating the release, failing early
+	otherwise
+	-timings: Report the time spent in each phase of the release, in each asset upload including
+	retries, the slowest asset and the total. Under -json, the report is added to the release as a timings object
+	-no-asset-verify: Trust a successful upload without checking the size of the resulting asset. This
+	saves a request and the -settle delay per asset, but a silently truncated upload goes unnoticed
+	-max-upload-rate <bytes/sec>: Limit the bandwidth used by all the uploads combined. Defaults to no limit
+	-refresh-signatures: Replace only the checksum manifests and signatures (.asc, .sig, .minisig) of the
+	release for <tag>, leaving its artifacts untouched. Signatures are taken from <files>, and manifests are
+	regenerated under -checksums from the artifacts, which are downloaded unless a copy is among <files>
+	-body-from-tag: Use the message of the annotated <tag> as the description of the release, falling back
+	to <description> when the tag is lightweight or doesn't exist yet
+	-preserve-draft-if-exists: Attach the files to the existing draft release for <tag>, if any, instead of
+	creating a new release. The draft is never published, whatever -draft says
+	-step-summary: Inside Github Actions, append to the step summary the name and link of the release and
+	a table of its uploaded assets. Does nothing when GITHUB_STEP_SUMMARY is not set
+	-fail-fast: Stop at the first asset that fails to upload, with exit status 3. This is the default. Under
+	-concurrency, the uploads already in progress are interrupted as well
+	-continue: Try to upload every asset even when some fail, then list all the errors and exit with
+	status 3. Same as -fail-fast=false
+	-verify-etag: Also compare the SHA-256 digest Github reports for each uploaded asset with the one of its
+	file, to catch corruption that leaves the size intact. github.com reports digests, while older Github
+	Enterprise servers don't, in which case only the size is checked. The ETag header of the responses
+	describes the JSON representation of the asset, not its content, and is not used
+	-target-file <path>: Read the target of the release, typically a commit SHA, from a file, taking
+	precedence over <branch> unless the file is empty. Under -verify-target, it must hold a commit SHA
+	-target <branch>: Reference from where to create <tag>, in place of the <branch> parameter, which must
+	then be left out
+	-allow-redirect-host <host,...>: Only follow redirects, e.g. from asset downloads to their storage, to
+	the given comma separated hosts, which can contain wildcards like *.amazonaws.com. Defaults to any host
+	-assets-from <file>: Also upload the files listed in a JSON file, as an array of objects like
+	{"path": "dist/app.tgz", "name": "app-linux.tgz", "label": "Linux", "content_type": "application/gzip"}.
+	Only "path" is required, the other fields override the defaults of the asset
+	-dry-run: Print the release that would be created or reused and the files that would be uploaded, with
+	their total size, without changing anything on Github. Given GITHUB_TOKEN, the existing release and
+	assets are looked up to tell which files would be skipped or replaced. Without, Github is not contacted.
+	Other modes, like -edit or -delete, only log the changes they would send
+	-assumed-bandwidth <bytes/sec>: Under -dry-run, estimate the upload time at this bandwidth, or at
+	-max-upload-rate if lower
+	-auto-label-platform: Label assets named after their platform, e.g. myapp_1.2.3_linux_amd64.tar.gz,
+	with a human name for it like "Linux x86-64". Assets with an explicit label are left alone
+	-platform-rules <file>: Recognize more platform tokens, or rename known ones, with one os:token=Name
+	or arch:token=Name rule per line, e.g. arch:loong64=LoongArch 64
+	-checksum-label: Label each asset with the SHA-256 of its file, as sha256:<hex>. An asset already
+	uploaded with a different checksum label is then replaced even if it has the right size
+	-delete-drafts-older-than <duration>: Delete the draft releases created more than <duration> ago, e.g.
+	72h or 30d, left behind by failed runs. Under -dry-run, they are only listed
+	-yes: Don't ask for confirmation before deleting draft releases, which is required outside of a terminal
+	-tmp-dir <path>: Directory in which temporary files, like generated checksum manifests and downloaded
+	artifacts, are written. Defaults to the system temporary directory
+	-skip-if-exists: Do nothing, successfully, when a release for <tag> already exists, draft or not,
+	instead of uploading the files to it. Unlike -no-reuse, which fails in that case
+	-body-stdin: Read the description of the release from stdin, as is, instead of <description>
+	-body-file <path>: Read the description of the release from a file, or from stdin if <path> is -,
+	instead of <description>
+	-body-trim: Strip the trailing newlines of the description read with -body-stdin or -body-file
+	-name <title>: Title of the release. Defaults to <tag>
+	-heal: Make the existing release for <tag> match <files> by uploading only the assets that are missing
+	or have the wrong size, then check the result, exiting with a non-zero status if it still doesn't match
+	-delete <tag>: Delete the release for <tag>. The tag itself is kept. Succeeds if there is no such release
+	-export-env: Write to stdout export lines for RELEASE_ID, RELEASE_HTML_URL and RELEASE_UPLOAD_URL,
+	quoted for the shell, e.g. for eval "$(github-release -export-env ...)"
+	-verify-checksum: Like -verify-etag, but when Github reports no digest for an asset, download it back
+	to compare its SHA-256 with the one of its file. A mismatching asset is deleted and uploaded again
+	-quiet: Only log errors, not the progress of the release like uploads, retries and deletions.
+	When logging to a terminal, the progress of each upload is otherwise logged every 5 seconds
+	-upload-url <url>: Uploads endpoint to send assets to instead of the one in the upload URL returned by
+	Github, e.g. https://github.example.com/api/uploads for Github Enterprise behind a proxy
+	-edit: Update the description of the existing release for <tag>, failing if there is none, without
+	touching its assets. Its name is updated under -name, and its state under -draft or -prerelease,
+	e.g. -prerelease=false to turn a prerelease into a full release
+	-asset-name <name>: Name of the asset uploaded from stdin when <files> is -, e.g.
+	build.sh | github-release -asset-name app.tar.gz <user/repo> <tag> <description> -. It is buffered to
+	a temporary file first, as Github needs to know its size
+	-list: Print every release of the repository with the names and sizes of its assets, or under -json
+	the releases as returned by Github
+	-config <file>: Read settings from a configuration file instead of .github-release.yml, which is
+	otherwise read from the working directory if it exists. See Configuration file below
+	-generate-notes: Have Github generate the release notes from the pull requests merged since the previous
+	release. They are appended to <description>, which can be left empty. Only applies when the release is
+	created, not to a reused release or a draft published with -draft-name
+	-discussion-category <name>: Start a discussion about the release in the given category of the
+	repository Discussions, which must be enabled. Only applies to created or published releases
+	-make-latest true|false|legacy: Whether Github marks the release as the latest one, e.g. false for a patch
+	release of an older version. legacy picks the latest by creation date and version. Defaults to Github's
+	choice, which is true for new releases. Also applies to -edit and drafts published with -draft-name
+	-strict-files: Fail before creating the release when "<files>" matches no file, e.g. after a failed build,
+	instead of only warning about it
+	-download <dir>: Download the assets of the existing release for <tag> into the given directory, created if
+	needed, instead of creating a release. Only the assets whose names match "<pattern>" are downloaded if given
+	-content-type <type>: Content type of the files matching no -content-type-rules rule, instead of the one
+	detected from their content or extension, e.g. application/octet-stream
+	-api-version <version>: Version of the Github REST API to use, sent as the X-GitHub-Api-Version header.
+	Defaults to 2022-11-28. Set to "" to leave the choice to the server, e.g. for older Github Enterprise servers
+	-tag-message <message>: Create <tag> as an annotated tag with the given message, on <branch> or else the
+	head of the default branch, instead of letting Github create a lightweight tag. An existing tag is left as is
+	-body-template: Render the description, wherever it comes from, as a Go template. It can refer to {{.Tag}},
+	{{.Name}}, {{.User}}, {{.Repo}}, {{.Target}}, {{.Commit}}, the SHA of the commit released, {{.Date}}, today's
+	date as 2006-01-02, and environment variables as {{.Env.NAME}}
+	-token-file <file>: Read the token from a file, e.g. a mounted secret, instead of GITHUB_TOKEN, which can
+	show up in process listings. Surrounding whitespace is ignored
+	-wait-for-asset <duration>: After uploading a file, wait up to the given time for Github to report its
+	asset as uploaded rather than still being processed, failing the upload otherwise. Off by default
+	-checksums-name <name>: Name of the manifest generated by -checksums, instead of e.g. SHA256SUMS.
+	Only a single -hash algorithm can be given along with it
+	-rename <file>=<name>: Upload the file matching "<files>" with the given path or base name as the asset
+	with the given name, e.g. -rename build/app=app-v1.2.3-linux-amd64. Can be repeated
 
 Environment variables:
   DEBUG: Allows you to run github-release in debugging mode. DO NOT do this if you are attempting to upload big files.
-  GITHUB_TOKEN: Must be set in order to interact with Github's API
+  GITHUB_TOKEN: Must be set in order to interact with Github's API, unless the token is read from a file
+  GITHUB_TOKEN_FILE: Default value for -token-file, taking precedence over GITHUB_TOKEN
   GITHUB_USER: Just in case you want an alternative way of providing your github user
   GITHUB_REPO: Just in case you want an alternative way of providing your github repo
   GITHUB_API: Github API endpoint. Set to https://api.github.com/repos/:github-user/:github-repo by default
+  GITHUB_RELEASE_RETRIES: Default value for -retries
+  GITHUB_UPLOAD_RETRIES: Default value for -upload-retries, taking precedence over -retries
+  GITHUB_RELEASE_TIMEOUT: Default value for -timeout
+  GITHUB_HTTP_TIMEOUT: Default value for -timeout, if GITHUB_RELEASE_TIMEOUT is not set
+  GITHUB_UPLOAD_API: Default value for -upload-url. Usually set along with GITHUB_API for Github Enterprise
+  GITHUB_RELEASE_CONCURRENCY: Default value for -concurrency
+
+Options given on the command line take precedence over their environment variables.
+
+Configuration file:
+  Settings can also be given by .github-release.yml, or the file given by -config, as key: value lines:
+	token: <token>
+	api: https://github.example.com/api/v3
+	user: octocat
+	repo: hello-world
+	retries: 3
+	prerelease: yes
+  token, api, user and repo stand for GITHUB_TOKEN, GITHUB_API, GITHUB_USER and GITHUB_REPO, and any other
+  key for the option of the same name. Given user and repo, <user/repo> can be left out of the command line,
+  unless <tag> has a slash in it.
+  Options given on the command line take precedence over environment variables, which take precedence
+  over the configuration file. Options turned on by the file can be turned off again, e.g. -prerelease=false
+
+Progress and error messages are written to stderr. Only the machine readable output of -json,
+-events-file -, -export-env and -diff is written to stdout, so that it can be piped, e.g. into jq.
 
 Before using this tool make sure you set the environment variable GITHUB_TOKEN
 with a valid Github token and correct authorization scopes to allow you to create releases
@@ -101,144 +626,1062 @@ License: http://mozilla.org/MPL/2.0/
 `
 
 func main() {
+	flag.Parse()
 	if verFlag {
 		log.Println(Version)
 		return
 	}
 
-	if flag.NArg() != 5 {
-		log.Printf("Error: Invalid number of arguments (got %d, expected 5)\n\n", flag.NArg())
-		log.Fatal(usage)
+	if err := loadConfig(); err != nil {
+		fatalln(err)
+	}
+	useConfiguredRepo()
+
+	removeTempDirsOnInterrupt()
+	defer removeTempDirs()
+
+	if quietFlag {
+		logLevel = levelError
+	}
+
+	nargs := 5
+	switch {
+	case checkFlag, diffFlag, refreshSignaturesFlag, healFlag, editFlag:
+		nargs = 3
+	case setPrereleaseFlag != "" || setDraftFlag != "", downloadFlag != "":
+		nargs = 2
+	case existsFlag != "", deleteDraftsFlag != "", deleteFlag != "", listFlag:
+		nargs = 1
+	}
+
+	// <branch> can be left out of a release, in favor of -target or of no target at all,
+	// and more patterns can follow "<files>".
+	if nargs == 5 && flag.NArg() >= 4 {
+		nargs = flag.NArg()
+	}
+	// The pattern of -download is optional.
+	if downloadFlag != "" && flag.NArg() == 3 {
+		nargs = 3
+	}
+
+	if flag.NArg() != nargs {
+		log.Printf("Error: Invalid number of arguments (got %d, expected %d)\n\n", flag.NArg(), nargs)
+		fatal(usage)
+	}
+
+	owner, repo, err := parseRepo(flag.Arg(0))
+	if err != nil {
+		log.Printf("%s\n\n", err)
+		fatal(usage)
+	}
+
+	if sniffBytesFlag <= 0 {
+		fatalf("Error: Invalid -sniff-bytes value: %d\n", sniffBytesFlag)
+	}
+	if contentTypeFlag != "" {
+		if _, _, err := mime.ParseMediaType(contentTypeFlag); err != nil {
+			fatalf("Error: Invalid -content-type value %q: %s\n", contentTypeFlag, err)
+		}
+	}
+
+	if retriesFlag < 0 {
+		fatalf("Error: Invalid -retries value: %d\n", retriesFlag)
+	}
+
+	for name, value := range map[string]*int{
+		"create-retries":   &createRetriesFlag,
+		"upload-retries":   &uploadRetriesFlag,
+		"metadata-retries": &metadataRetriesFlag,
+	} {
+		switch {
+		case isFlagSet(name):
+			if *value < 0 {
+				fatalf("Error: Invalid -%s value: %d\n", name, *value)
+			}
+		case name == "upload-retries" && os.Getenv("GITHUB_UPLOAD_RETRIES") != "":
+			*value = envInt("GITHUB_UPLOAD_RETRIES", retriesFlag)
+		default:
+			*value = retriesFlag
+		}
+	}
+
+	if timeoutFlag < 0 {
+		fatalf("Error: Invalid -timeout value: %s\n", timeoutFlag)
+	}
+
+	if checksumsFlag {
+		if err := checkManifestOptions(); err != nil {
+			fatalln(err)
+		}
+	}
+
+	if settleFlag < 0 {
+		fatalf("Error: Invalid -settle value: %s\n", settleFlag)
+	}
+	if waitForAssetFlag < 0 {
+		fatalf("Error: Invalid -wait-for-asset value: %s\n", waitForAssetFlag)
+	}
+
+	if isFlagSet("parallel") {
+		if isFlagSet("concurrency") && concurrencyFlag != parallelFlag {
+			fatal("Error: -parallel and -concurrency cannot be given different values")
+		}
+		concurrencyFlag = parallelFlag
+
+		// Parallel uploads report all their failures at the end, unless told otherwise.
+		if !isFlagSet("fail-fast") {
+			continueFlag = true
+		}
+	}
+	if concurrencyFlag <= 0 {
+		fatalf("Error: Invalid -concurrency value: %d\n", concurrencyFlag)
+	}
+	if connectTimeoutFlag < 0 {
+		fatalf("Error: Invalid -connect-timeout value: %s\n", connectTimeoutFlag)
+	}
+	httpClient.Timeout = timeoutFlag
+	httpClient.Transport = newTransport(connectTimeoutFlag)
+	httpClient.CheckRedirect = checkRedirect
+	for _, pattern := range strings.Split(allowRedirectHostFlag, ",") {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			fatalf("Error: Invalid -allow-redirect-host pattern: %s\n", pattern)
+		}
+	}
+
+	if uploadConcurrencyFlag < 0 {
+		fatalf("Error: Invalid -upload-concurrency value: %d\n", uploadConcurrencyFlag)
+	}
+	if uploadConcurrencyFlag == 0 {
+		uploadConcurrencyFlag = concurrencyFlag
+	}
+	uploadSlots = make(chan struct{}, uploadConcurrencyFlag)
+
+	if uploadAPIFlag != "" {
+		if u, err := url.Parse(uploadAPIFlag); err != nil || u.Scheme == "" || u.Host == "" {
+			fatalf("Error: Invalid -upload-url value: %s\n", uploadAPIFlag)
+		}
+	}
+
+	switch makeLatestFlag {
+	case "", "true", "false", "legacy":
+	default:
+		fatalf("Error: Invalid -make-latest value: %q, true, false or legacy is expected\n", makeLatestFlag)
+	}
+
+	if tmpDirFlag != "" {
+		if stat, err := os.Stat(tmpDirFlag); err != nil || !stat.IsDir() {
+			fatalf("Error: Invalid -tmp-dir value: %s is not a directory\n", tmpDirFlag)
+		}
+	}
+
+	if assumedBandwidthFlag < 0 {
+		fatalf("Error: Invalid -assumed-bandwidth value: %d\n", assumedBandwidthFlag)
+	}
+
+	if maxUploadRateFlag < 0 {
+		fatalf("Error: Invalid -max-upload-rate value: %d\n", maxUploadRateFlag)
+	}
+	if maxUploadRateFlag > 0 {
+		uploadLimiter = newRateLimiter(maxUploadRateFlag)
+	}
+
+	if ownerTypeFlag != "" && ownerTypeFlag != ownerOrg && ownerTypeFlag != ownerUser {
+		fatalf("Error: Invalid -owner-type value: %s, org or user is expected\n", ownerTypeFlag)
+	}
+
+	if resumeFlag && replaceFlag {
+		fatal("Error: -resume and -replace (or -clobber) cannot be used together")
+	}
+
+	if bodyStdinFlag && bodyFromTagFlag {
+		fatal("Error: -body-stdin and -body-from-tag cannot be used together")
+	}
+	if bodyFileFlag != "" && (bodyStdinFlag || bodyFromTagFlag) {
+		fatal("Error: -body-file cannot be used with -body-stdin or -body-from-tag")
+	}
+
+	if noReuseFlag && skipIfExistsFlag {
+		fatal("Error: -no-reuse and -skip-if-exists cannot be used together")
+	}
+
+	if noReuseFlag && draftNameFlag != "" {
+		fatal("Error: -no-reuse and -draft-name cannot be used together")
+	}
+
+	if noReuseFlag && editFlag {
+		fatal("Error: -no-reuse and -edit cannot be used together")
 	}
 
-	userRepo := strings.Split(flag.Arg(0), "/")
-	if len(userRepo) != 2 {
-		log.Printf("Error: Invalid format used for username and repository: %s\n\n", flag.Arg(0))
-		log.Fatal(usage)
+	if continueFlag && failFastFlag && isFlagSet("fail-fast") {
+		fatal("Error: -fail-fast and -continue cannot be used together")
+	}
+	if !failFastFlag {
+		continueFlag = true
+	}
+
+	if noAssetVerifyFlag && strictContentLengthFlag {
+		fatal("Error: -no-asset-verify and -strict-content-length cannot be used together")
+	}
+	if checksumLabelFlag && autoLabelPlatformFlag {
+		fatal("Error: -checksum-label and -auto-label-platform cannot be used together")
 	}
 
-	if githubToken == "" {
-		log.Fatal(`Error: GITHUB_TOKEN environment variable is not set.
+	if noAssetVerifyFlag && (verifyEtagFlag || verifyChecksumFlag) {
+		fatal("Error: -no-asset-verify cannot be used with -verify-etag or -verify-checksum")
+	}
+
+	if downloadsTemplateFlag != "" {
+		if err := loadDownloadsTemplate(downloadsTemplateFlag); err != nil {
+			fatalln(err)
+		}
+	}
+
+	if tokenFileFlag != "" {
+		token, err := readTokenFile(tokenFileFlag)
+		if err != nil {
+			fatalln(err)
+		}
+		githubToken = token
+	}
+
+	if githubToken == "" && (!dryRunFlag || deleteDraftsFlag != "") {
+		fatal(`Error: GITHUB_TOKEN environment variable is not set, nor is GITHUB_TOKEN_FILE or -token-file.
 Please refer to https://help.github.com/articles/creating-an-access-token-for-command-line-use/ for more help`)
 	}
 
-	githubUser = userRepo[0]
-	githubRepo = userRepo[1]
+	githubUser = owner
+	githubRepo = repo
+	githubAPIRoot = githubAPIEndpoint
 	githubAPIEndpoint = fmt.Sprintf("%s/repos/%s/%s", githubAPIEndpoint, githubUser, githubRepo)
 
+	if setPrereleaseFlag != "" || setDraftFlag != "" {
+		setReleaseFlags(flag.Arg(1))
+		return
+	}
+
+	if editFlag {
+		desc, err := renderBody(releaseBody(flag.Arg(2)), flag.Arg(1), "")
+		if err != nil {
+			fatalln(err)
+		}
+		editRelease(flag.Arg(1), desc)
+		return
+	}
+
+	if listFlag {
+		listAll()
+		return
+	}
+
+	if downloadFlag != "" {
+		if err := downloadRelease(flag.Arg(1), downloadFlag, flag.Arg(2)); err != nil {
+			fatalln(err)
+		}
+		return
+	}
+
+	if existsFlag != "" {
+		exit(releaseExists(existsFlag))
+	}
+
+	if deleteFlag != "" {
+		if err := deleteRelease(deleteFlag); err != nil {
+			fatalln(err)
+		}
+		return
+	}
+
+	if deleteDraftsFlag != "" {
+		age, err := parseAge(deleteDraftsFlag)
+		if err != nil {
+			fatalln(err)
+		}
+		if err := deleteOldDrafts(age); err != nil {
+			fatalln(err)
+		}
+		return
+	}
+
+	if diffFlag {
+		if !diffReleases(flag.Arg(1), flag.Arg(2)) {
+			exit(diffExitCodeFlag)
+		}
+		return
+	}
+
+	if checkFlag {
+		files, err := collectAssetFiles(globAssetFiles(flag.Arg(2)), assetFlags)
+		if err != nil {
+			fatalln(err)
+		}
+		if !checkRelease(flag.Arg(1), files) {
+			exit(1)
+		}
+		return
+	}
+
+	if refreshSignaturesFlag {
+		files, err := collectAssetFiles(globAssetFiles(flag.Arg(2)), assetFlags)
+		if err != nil {
+			fatalln(err)
+		}
+		refreshSignatures(flag.Arg(1), files)
+		infof("Done\n")
+		return
+	}
+
+	if healFlag {
+		files, err := collectAssetFiles(globAssetFiles(flag.Arg(2)), assetFlags)
+		if err != nil {
+			fatalln(err)
+		}
+		if !healRelease(flag.Arg(1), files) {
+			exit(1)
+		}
+		return
+	}
+
+	extra := []assetFile(assetFlags)
+	if assetsFromFlag != "" {
+		spec, err := loadAssetSpec(assetsFromFlag)
+		if err != nil {
+			fatalln(err)
+		}
+		extra = append(extra, spec...)
+	}
+
+	args, err := releaseArgs(flag.Args())
+	if err != nil {
+		log.Printf("%s\n\n", err)
+		fatal(usage)
+	}
+	patterns := args[4:]
+
+	var globbed []assetFile
+	if len(patterns) == 1 && patterns[0] == "-" {
+		if assetNameFlag == "" {
+			fatalln("Error: -asset-name is required to upload stdin")
+		}
+		if bodyStdinFlag || bodyFileFlag == "-" {
+			fatalln("Error: The description and the asset cannot both be read from stdin")
+		}
+		file, err := stdinAssetFile(assetNameFlag)
+		if err != nil {
+			fatalf("Error: Unable to read the asset from stdin: %s\n", err)
+		}
+		globbed = []assetFile{file}
+	} else if assetNameFlag != "" {
+		fatalln("Error: -asset-name can only be given when <files> is -, to upload stdin")
+	} else {
+		globbed = globPatterns(patterns)
+	}
+
+	files, err := collectAssetFiles(globbed, extra)
+	if err != nil {
+		fatalln(err)
+	}
+
+	if platformRulesFlag != "" {
+		if err := loadPlatformRules(platformRulesFlag); err != nil {
+			fatalln(err)
+		}
+	}
+	if autoLabelPlatformFlag {
+		for i := range files {
+			if files[i].label == "" {
+				files[i].label = platformLabel(files[i].name)
+			}
+		}
+	}
+
+	if checksumLabelFlag {
+		for i := range files {
+			if files[i].label != "" {
+				continue
+			}
+			sum, err := fileSHA256(files[i].path)
+			if err != nil {
+				fatalln(err)
+			}
+			files[i].label = checksumLabelPrefix + sum
+		}
+	}
+
+	if contentTypeRulesFlag != "" {
+		rules, err := loadContentTypeRules(contentTypeRulesFlag)
+		if err != nil {
+			fatalln(err)
+		}
+		contentTypeRules = rules
+
+		if verboseFlag {
+			reportContentTypeRules(files)
+		}
+	}
+
+	if err := checkAssetSizes(files); err != nil {
+		fatalln(err)
+	}
+
+	if checksumsFlag {
+		dir := manifestDirFlag
+		if dir == "" {
+			tmpDir, err := newTempDir()
+			if err != nil {
+				fatalln(err)
+			}
+			dir = tmpDir
+		}
+
+		files = withoutManifests(files)
+		manifests, err := generateManifests(dir, files)
+		if err != nil {
+			fatalln(err)
+		}
+		files = append(files, newAssetFiles(manifests)...)
+	}
+
+	if fromTarFlag != "" {
+		if err := checkTarAssets(fromTarFlag, files); err != nil {
+			fatalln(err)
+		}
+	}
+
+	tag := args[1]
+	branch := args[2]
+	desc := args[3]
+
+	desc = releaseBody(desc)
+
+	if targetFileFlag != "" {
+		data, err := ioutil.ReadFile(targetFileFlag)
+		if err != nil {
+			fatalf("Error: Unable to read -target-file: %s\n", err)
+		}
+		if target := strings.TrimSpace(string(data)); target != "" {
+			if verifyTargetFlag && !looksLikeSHA(target) {
+				fatalf("Error: -target-file %s does not hold a commit SHA: %s\n", targetFileFlag, target)
+			}
+			branch = target
+		}
+	}
+
+	if dryRunFlag {
+		release := Release{
+			TagName:    tag,
+			Name:       releaseName(tag),
+			Prerelease: prereleaseFlag,
+			Draft:      draftFlag,
+			Branch:     branch,
+
+			GenerateNotes:      generateNotesFlag,
+			DiscussionCategory: discussionCategoryFlag,
+			MakeLatest:         makeLatestFlag,
+		}
+		if err := printPlan(release, files); err != nil {
+			fatalln(err)
+		}
+		return
+	}
+
+	if skipIfExistsFlag {
+		existing, err := findReleaseByTag(tag)
+		if err != nil && !hasStatus(err, http.StatusNotFound) {
+			fatalln(err)
+		}
+		if err == nil {
+			infof("Release %s already exists (id %d), skipping as -skip-if-exists was given.\n", tag, existing.ID)
+			return
+		}
+	}
+
+	if bodyFromTagFlag {
+		annotation, err := tagAnnotation(tag)
+		if err != nil {
+			fatalln(err)
+		}
+		if annotation != "" {
+			desc = annotation
+		} else {
+			infof("Tag %s has no annotation, using the description given.\n", tag)
+		}
+	}
+
+	branch, err = releaseTarget(branch)
+	if err != nil {
+		fatalln(err)
+	}
+
+	if tagMessageFlag != "" {
+		if err := createAnnotatedTag(tag, branch, tagMessageFlag); err != nil {
+			fatalln(err)
+		}
+	}
+
+	desc, err = renderBody(desc, tag, branch)
+	if err != nil {
+		fatalln(err)
+	}
+
+	release := Release{
+		TagName:       tag,
+		Name:          releaseName(tag),
+		Prerelease:    prereleaseFlag,
+		Draft:         draftFlag,
+		Branch:        branch,
+		Body:          desc,
+		GenerateNotes: generateNotesFlag,
+
+		DiscussionCategory: discussionCategoryFlag,
+		MakeLatest:         makeLatestFlag,
+	}
+	release, err = publishRelease(release, files)
+	if err != nil {
+		log.Println(err)
+		exit(exitUploadFailed)
+	}
+	infof("Done\n")
+
+	switch {
+	case jsonFlag:
+		if err := printRelease(release); err != nil {
+			fatalln(err)
+		}
+	case timingsFlag:
+		timings.Print()
+	}
+}
+
+func expandGlob(pattern string) []string {
 	if debug {
 		log.Println("Glob pattern received: ")
-		log.Println(flag.Arg(4))
+		log.Println(pattern)
+	}
+
+	glob := filepath.Glob
+	if strings.Contains(pattern, "**") {
+		glob = recursiveGlob
 	}
 
-	filepaths, err := filepath.Glob(flag.Arg(4))
+	filepaths, err := glob(pattern)
 	if err != nil {
-		log.Fatalf("Error: Invalid glob pattern: %s\n", flag.Arg(4))
+		fatalf("Error: Invalid glob pattern: %s\n", pattern)
 	}
 
 	if debug {
 		log.Println("Expanded glob pattern: ")
 		log.Printf("%v\n", filepaths)
 	}
-
-	tag := flag.Arg(1)
-	branch := flag.Arg(2)
-	desc := flag.Arg(3)
-
-	release := Release{
-		TagName:    tag,
-		Name:       tag,
-		Prerelease: prereleaseFlag,
-		Draft:      draftFlag,
-		Branch:     branch,
-		Body:       desc,
-	}
-	publishRelease(release, filepaths)
-	log.Println("Done")
+	return filepaths
 }
 
-func uploadFile(uploadURL, path string) {
-	file, err := os.Open(path)
+func uploadFile(uploadURL string, asset assetFile, label string) error {
+	file, err := os.Open(asset.path)
 	if err != nil {
-		log.Printf("Error: %s\n", err.Error())
-		return
+		return err
 	}
 	defer file.Close()
 
 	size, err := fileSize(file)
 	if err != nil {
-		log.Printf("Error: %s\n", err.Error())
-		return
+		return err
 	}
 
-	filename := filepath.Base(file.Name())
-	log.Printf("Uploading %s...\n", filename)
-	body, err := doRequest("POST", uploadURL+"?name="+filename, "application/octet-stream", file, size)
+	contentType, err := assetContentType(asset, file, size)
 	if err != nil {
-		log.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	return uploadAsset(uploadURL, asset.name, label, contentType, file, size)
+}
+
+// assetContentType returns the content type the asset was given, or else the one of the
+// first -content-type-rules rule it matches, or else the one given by -content-type, or
+// else the one detected from the file.
+func assetContentType(asset assetFile, file io.ReaderAt, size int64) (string, error) {
+	if asset.contentType != "" {
+		return asset.contentType, nil
+	}
+	if contentType := matchContentTypeRule(asset); contentType != "" {
+		return contentType, nil
+	}
+	if contentTypeFlag != "" {
+		return contentTypeFlag, nil
+	}
+	return detectContentType(asset.name, file, size, sniffBytesFlag)
+}
+
+// uploadAsset streams size bytes read from r to the release as an asset called name.
+// The asset label is only set if not empty.
+func uploadAsset(uploadURL, name, label, contentType string, r io.Reader, size int64) error {
+	endpoint := uploadEndpoint(uploadURL, name, label)
+
+	uploadSlots <- struct{}{}
+	defer func() { <-uploadSlots }()
+
+	if uploadLimiter != nil {
+		r = &rateLimitedReader{r: r, limiter: uploadLimiter}
 	}
+	if size > 0 && showProgress() {
+		r = newProgressReader(r, "Uploading", name, size)
+	}
+
+	assetLogf(levelInfo, name, "Uploading %s...\n", name)
+	body, err := doRequest("POST", endpoint, contentType, r, size)
 
 	if debug {
 		log.Println("========= UPLOAD RESPONSE ===========")
 		log.Println(string(body[:]))
 	}
+	return err
+}
+
+// uploadEndpoint returns the URL the asset with the given name and label is uploaded to,
+// both of which can hold any character.
+func uploadEndpoint(uploadURL, name, label string) string {
+	endpoint := uploadURL + "?name=" + url.QueryEscape(name)
+	if label != "" {
+		endpoint += "&label=" + url.QueryEscape(label)
+	}
+	return endpoint
+}
+
+// releaseBody returns the description of the release, read from stdin or from a file
+// under -body-stdin or -body-file, or else the one given on the command line.
+func releaseBody(desc string) string {
+	if !bodyStdinFlag && bodyFileFlag == "" {
+		return desc
+	}
+
+	var data []byte
+	var err error
+	if bodyStdinFlag || bodyFileFlag == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(bodyFileFlag)
+	}
+	if err != nil {
+		fatalf("Error: Unable to read the description: %s\n", err)
+	}
+	desc = string(data)
+	if bodyTrimFlag {
+		desc = strings.TrimRight(desc, "\r\n")
+	}
+	return desc
+}
+
+// releaseArgs returns the arguments of a release as <user/repo> <tag> <branch> <description>
+// followed by the patterns, with <branch> taken from -target when it is left out. A <branch>
+// given along with -target pushes <description> among the patterns, where it names no file.
+func releaseArgs(args []string) ([]string, error) {
+	if len(args) != 4 && targetFlag == "" {
+		return args, nil
+	}
+	if len(args) > 4 && !isFilePattern(args[3]) {
+		return nil, errors.New("Error: -target cannot be combined with <branch>, leave out one of them")
+	}
+	return append([]string{args[0], args[1], targetFlag}, args[2:]...), nil
+}
+
+// isFilePattern tells whether an argument can stand for files to upload: stdin, a glob, or
+// a path that exists, labelled or not.
+func isFilePattern(arg string) bool {
+	if arg == "" || arg == "-" || hasMeta(arg) {
+		return true
+	}
+	filepaths, _ := expandAssetPattern(arg)
+	return len(filepaths) > 0
+}
+
+// releaseName returns the title of the release for tag, given by -name or else the tag itself.
+func releaseName(tag string) string {
+	if nameFlag != "" {
+		return nameFlag
+	}
+	return tag
 }
 
 // CreateRelease creates a Github Release, attaching the given files as release assets
 // If a release already exist, up in Github, this function will attempt to attach the given files to it.
-func CreateRelease(tag, branch, desc string, filepaths []string) {
+func CreateRelease(tag, branch, desc string, filepaths []string) error {
 	release := Release{
 		TagName:    tag,
-		Name:       tag,
-		Prerelease: false,
-		Draft:      false,
+		Name:       releaseName(tag),
+		Prerelease: prereleaseFlag,
+		Draft:      draftFlag,
 		Branch:     branch,
 		Body:       desc,
 	}
-	publishRelease(release, filepaths)
+	_, err := publishRelease(release, newAssetFiles(filepaths))
+	return err
 }
 
-func publishRelease(release Release, filepaths []string) {
-	endpoint := fmt.Sprintf("%s/releases", githubAPIEndpoint)
-	releaseData, err := json.Marshal(release)
-	if err != nil {
-		log.Fatalln(err)
+// publishRelease creates the release, or reuses the existing one for its tag, attaches
+// the files to it and returns it, along with an error if some files could not be uploaded.
+func publishRelease(release Release, files []assetFile) (Release, error) {
+	if draftNameFlag != "" {
+		return publishDraft(release, files)
+	}
+
+	if preserveDraftFlag {
+		existing, err := findReleaseByTag(release.TagName)
+		if err != nil && !hasStatus(err, http.StatusNotFound) {
+			fatalln(err)
+		}
+		if err == nil && existing.Draft {
+			infof("Reusing draft release %q (id %d) for tag %s, it is left as a draft.\n", existing.Name, existing.ID, existing.TagName)
+			if err := reportRelease(existing); err != nil {
+				fatalln(err)
+			}
+			return existing, uploadFiles(existing, files)
+		}
 	}
 
-	releaseBuffer := bytes.NewBuffer(releaseData)
+	start := time.Now()
+	if err := omitExistingTagTarget(&release); err != nil {
+		fatalln(err)
+	}
+	timings.Track("tag check", start)
 
-	data, err := doRequest("POST", endpoint, "application/json", releaseBuffer, int64(releaseBuffer.Len()))
+	start = time.Now()
+	data, err := createRelease(release)
+	timings.Track("create", start)
+	if hasStatus(err, http.StatusNotFound) || hasStatus(err, http.StatusForbidden) || hasStatus(err, http.StatusUnauthorized) {
+		fatalln(explainAccessError(err, fmt.Sprintf("%s/releases", githubAPIEndpoint)))
+	}
 
-	if err != nil && data != nil {
+	if err != nil && release.DiscussionCategory != "" {
+		if msg := discussionError(data); msg != "" {
+			fatalln(discussionCategoryError(release.DiscussionCategory, msg))
+		}
+	}
+
+	if err != nil && noReuseFlag && isAlreadyExists(data) {
+		fatalf("Error: A release for tag %s already exists and -no-reuse was given\n", release.TagName)
+	}
+
+	if err != nil && data != nil && !noReuseFlag {
 		log.Println(err)
-		log.Println("Trying again assuming release already exists.")
-		endpoint = fmt.Sprintf("%s/releases/tags/%s", githubAPIEndpoint, release.TagName)
-		data, err = doRequest("GET", endpoint, "application/json", nil, int64(0))
+		infof("Trying again assuming release already exists.\n")
+		endpoint := fmt.Sprintf("%s/releases/tags/%s", githubAPIEndpoint, release.TagName)
+		data, _, err = lookup(endpoint)
 	}
 
 	if err != nil {
-		log.Fatalln(err)
+		fatalln(err)
 	}
 
 	// Gets the release Upload URL from the returned JSON data
 	err = json.Unmarshal(data, &release)
 	if err != nil {
-		log.Fatalln(err)
+		fatalln(err)
+	}
+	if release.Draft {
+		infof("Release %s is a draft, it is left as a draft.\n", release.TagName)
+	}
+
+	if err := reportRelease(release); err != nil {
+		fatalln(err)
+	}
+
+	if err := uploadFiles(release, files); err != nil {
+		return release, err
+	}
+	logDiscussion(release)
+	return release, nil
+}
+
+// reportRelease makes the release about to receive the files known to the caller, through
+// -id-file and -export-env.
+func reportRelease(release Release) error {
+	if err := writeReleaseID(release); err != nil {
+		return err
+	}
+	if exportEnvFlag {
+		return printExports(release)
+	}
+	return nil
+}
+
+// writeReleaseID writes the ID of the release to the file given by -id-file, if any.
+func writeReleaseID(release Release) error {
+	if idFileFlag == "" {
+		return nil
+	}
+	return ioutil.WriteFile(idFileFlag, []byte(strconv.FormatInt(release.ID, 10)), 0644)
+}
+
+// createRelease sends the request creating the release, retrying with exponential backoff
+// when no response or a server error was received, or after the delay Github asked for
+// when rate limited.
+// Since such a request may have succeeded nonetheless, the release is looked up by tag
+// before trying again so that it never gets created twice. A release found that predates
+// the first request was not created by it, and is only reused when -no-reuse is not given.
+func createRelease(release Release) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/releases", githubAPIEndpoint)
+	releaseData, err := json.Marshal(release)
+	if err != nil {
+		return nil, err
+	}
+
+	// Github gives the creation time of releases to the second.
+	first := time.Now().Truncate(time.Second)
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		data, err := doRequest("POST", endpoint, "application/json", bytes.NewReader(releaseData), int64(len(releaseData)))
+		if err == nil || !isRetryable(err) || attempt >= createRetriesFlag {
+			return data, err
+		}
+
+		log.Printf("Error: %s\n", err)
+		time.Sleep(retryDelay(err, backoff))
+		backoff *= 2
+
+		// Drafts are not found by tag, and Github accepts several of them for the same tag.
+		if found, err := findReleaseByTag(release.TagName); err == nil && found.Draft == release.Draft {
+			if found.CreatedAt != nil && !found.CreatedAt.Before(first) {
+				infof("Release %s was created despite the error.\n", release.TagName)
+				return json.Marshal(found)
+			}
+			if !noReuseFlag {
+				infof("Release %s already exists, it is reused.\n", release.TagName)
+				return json.Marshal(found)
+			}
+			// Under -no-reuse, another draft can still be created next to an older one.
+			if !release.Draft {
+				return nil, fmt.Errorf("Error: A release for tag %s already exists and -no-reuse was given", release.TagName)
+			}
+		}
+		infof("Retrying creation of release %s...\n", release.TagName)
+	}
+}
+
+// publishDraft attaches the given files to the existing draft named after -draft-name and,
+// unless the release is meant to stay a draft, publishes it using the requested tag and branch.
+// A draft to which some files could not be uploaded is not published.
+func publishDraft(release Release, files []assetFile) (Release, error) {
+	start := time.Now()
+	draft, err := findDraftByName(draftNameFlag)
+	if err != nil {
+		fatalln(err)
+	}
+	timings.Track("draft lookup", start)
+	infof("Using draft release %q (id %d).\n", draft.Name, draft.ID)
+
+	if err := reportRelease(draft); err != nil {
+		fatalln(err)
+	}
+
+	if err := uploadFiles(draft, files); err != nil {
+		return draft, err
+	}
+
+	if release.Draft {
+		return draft, nil
+	}
+
+	update := map[string]interface{}{
+		"tag_name":   release.TagName,
+		"prerelease": release.Prerelease,
+		"draft":      false,
+	}
+	if err := omitExistingTagTarget(&release); err != nil {
+		fatalln(err)
+	}
+	if release.Branch != "" {
+		update["target_commitish"] = release.Branch
+	}
+	if nameFlag != "" {
+		update["name"] = release.Name
+	}
+	if release.DiscussionCategory != "" {
+		update["discussion_category_name"] = release.DiscussionCategory
+	}
+	if release.MakeLatest != "" {
+		update["make_latest"] = release.MakeLatest
+	}
+	updateData, err := json.Marshal(update)
+	if err != nil {
+		fatalln(err)
+	}
+
+	infof("Publishing draft release %q as %s...\n", draft.Name, release.TagName)
+	endpoint := fmt.Sprintf("%s/releases/%d", githubAPIEndpoint, draft.ID)
+	data, err := doRequest("PATCH", endpoint, "application/json", bytes.NewBuffer(updateData), int64(len(updateData)))
+	if msg := discussionError(data); err != nil && release.DiscussionCategory != "" && msg != "" {
+		fatalln(discussionCategoryError(release.DiscussionCategory, msg))
+	}
+	if err != nil {
+		fatalln(err)
+	}
+
+	var published Release
+	if err := json.Unmarshal(data, &published); err != nil {
+		fatalln(err)
 	}
+	logDiscussion(published)
+	return published, nil
+}
+
+// logDiscussion logs the URL of the discussion linked to the release, if one was created.
+func logDiscussion(release Release) {
+	if release.DiscussionURL != "" {
+		infof("Release discussion: %s\n", release.DiscussionURL)
+	}
+}
 
+// overrideUploadURL moves the upload URL returned by Github to the uploads endpoint given by
+// -upload-url, keeping its path from /repos/ on. It is returned as is without -upload-url.
+func overrideUploadURL(uploadURL string) (string, error) {
+	if uploadAPIFlag == "" {
+		return uploadURL, nil
+	}
+
+	i := strings.Index(uploadURL, "/repos/")
+	if i < 0 {
+		return "", fmt.Errorf("Error: Unexpected upload URL: %s", uploadURL)
+	}
+	return strings.TrimSuffix(uploadAPIFlag, "/") + uploadURL[i:], nil
+}
+
+// exitUploadFailed is the exit status when some of the files could not be uploaded.
+const exitUploadFailed = 3
+
+// uploadFiles uploads the files to the release, returning an error if any of them could not
+// be uploaded with the right size despite the retries. Unless under -continue, the run is
+// stopped at the first failure instead, with the exitUploadFailed status.
+func uploadFiles(release Release, files []assetFile) error {
 	// Upload URL comes like this https://uploads.github.com/repos/octocat/Hello-World/releases/1/assets{?name}
 	// So we need to remove the {?name} part
-	uploadURL := strings.Split(release.UploadURL, "{")[0]
+	uploadURL, err := overrideUploadURL(strings.Split(release.UploadURL, "{")[0])
+	if err != nil {
+		fatalln(err)
+	}
+
+	results, err := newUploadResults(eventsFileFlag)
+	if err != nil {
+		fatalln(err)
+	}
+	defer results.Close()
 
+	if resumeFlag {
+		files = skipUploadedFiles(release, files, results)
+	}
+
+	start := time.Now()
+	defer timings.Track("uploads", start)
+
+	queue := make(chan assetFile)
 	var wg sync.WaitGroup
-	for i := range filepaths {
+	for i := 0; i < concurrencyFlag; i++ {
 		wg.Add(1)
-		func(index int) {
-			uploadFile(uploadURL, filepaths[index])
-			wg.Done()
-		}(i)
+		go func() {
+			defer wg.Done()
+			for file := range queue {
+				start := time.Now()
+				uploaded, err := uploadFileWithRetry(release, uploadURL, file)
+				if err == nil && uploaded && waitForAssetFlag > 0 && !dryRunFlag {
+					err = waitForAsset(release, file.name, waitForAssetFlag)
+				}
+				results.Record(file.name, localSize(file.path), uploaded, err, time.Since(start))
+				if err != nil && !continueFlag {
+					results.Close()
+					log.Println(err)
+					exit(exitUploadFailed)
+				}
+			}
+		}()
 	}
+
+	for _, file := range files {
+		queue <- file
+	}
+	close(queue)
 	wg.Wait()
+
+	var tarErr error
+	if fromTarFlag != "" {
+		tarErr = uploadTar(release, uploadURL, fromTarFlag, results)
+		if tarErr != nil && !continueFlag {
+			results.Close()
+			log.Println(tarErr)
+			exit(exitUploadFailed)
+		}
+	}
+
+	results.Summarize()
+	failures := results.Failures()
+
+	if stepSummaryFlag {
+		if err := writeStepSummary(release, results); err != nil {
+			log.Printf("Error: Unable to write the step summary: %s\n", err)
+		}
+	}
+
+	if len(failures) > 0 {
+		log.Printf("Error: %d asset(s) failed to upload:\n", len(failures))
+		for _, f := range failures {
+			log.Printf("  %s\n", f.Error)
+		}
+		return fmt.Errorf("Error: %d asset(s) failed to upload", len(failures))
+	}
+	if tarErr != nil {
+		return tarErr
+	}
+
+	if appendDownloadsTableFlag {
+		if err := appendDownloadsTable(release); err != nil {
+			fatalln(err)
+		}
+	}
+	return nil
+}
+
+// localSize returns the size of a local file, or -1 if it cannot be determined.
+func localSize(path string) int64 {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return -1
+	}
+	return stat.Size()
+}
+
+// discussionError returns the message of the error about the discussion category in a Github
+// error response, if any.
+func discussionError(data []byte) string {
+	resp, ok := parseErrorBody(data)
+	if !ok {
+		return ""
+	}
+	for _, e := range resp.Errors {
+		if e.Field == "discussion_category_name" || strings.Contains(strings.ToLower(e.Message), "discussion") {
+			if e.Message != "" {
+				return e.Message
+			}
+			return e.describe()
+		}
+	}
+	if strings.Contains(strings.ToLower(resp.Message), "discussion") {
+		return resp.Message
+	}
+	return ""
+}
+
+// discussionCategoryError explains Github rejecting the discussion category of a release.
+func discussionCategoryError(category, msg string) error {
+	return fmt.Errorf("Error: Github rejected -discussion-category %q: %s\n"+
+		"Make sure Discussions are enabled for the repository and the category exists.", category, msg)
+}
+
+// isAlreadyExists tells whether a Github error response reports that the resource already exists.
+func isAlreadyExists(data []byte) bool {
+	resp, _ := parseErrorBody(data)
+	for _, e := range resp.Errors {
+		if e.Code == "already_exists" {
+			return true
+		}
+	}
+	return false
 }
 
 func fileSize(file *os.File) (int64, error) {
@@ -249,16 +1692,53 @@ func fileSize(file *os.File) (int64, error) {
 	return stat.Size(), nil
 }
 
+// defaultAPIVersion is the version of the Github REST API requests are made against.
+const defaultAPIVersion = "2022-11-28"
+
+// setAPIVersion pins the request to the API version given by -api-version, if any.
+func setAPIVersion(req *http.Request) {
+	if apiVersionFlag != "" {
+		req.Header.Set("X-GitHub-Api-Version", apiVersionFlag)
+	}
+}
+
+var authorizationHeader = regexp.MustCompile(`(?mi)^(Authorization: *\S+ ).*$`)
+
+// redactDump returns a request or response dump under DEBUG without the token, so that
+// it does not end up in CI logs.
+func redactDump(dump []byte) string {
+	s := authorizationHeader.ReplaceAllString(string(dump), "${1}[REDACTED]")
+	if githubToken != "" {
+		s = strings.Replace(s, githubToken, "[REDACTED]", -1)
+	}
+	return s
+}
+
 // Sends HTTP request to Github API
 func doRequest(method, url, contentType string, reqBody io.Reader, bodySize int64) ([]byte, error) {
+	body, _, err := doRequestWithHeader(method, url, contentType, reqBody, bodySize)
+	return body, err
+}
+
+// Sends HTTP request to Github API, also returning the response headers
+func doRequestWithHeader(method, url, contentType string, reqBody io.Reader, bodySize int64) ([]byte, http.Header, error) {
 	req, err := http.NewRequest(method, url, reqBody)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// Under -dry-run, only lookups are sent, changes are pretended to succeed.
+	if dryRunFlag && method != "GET" {
+		infof("Dry run, would send %s %s\n", method, url)
+		return []byte("{}"), nil, nil
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("token %s", githubToken))
+	if githubToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", githubToken))
+	}
 	req.Header.Set("Content-type", contentType)
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	setAPIVersion(req)
 	req.ContentLength = bodySize
 
 	if debug {
@@ -267,10 +1747,10 @@ func doRequest(method, url, contentType string, reqBody io.Reader, bodySize int6
 		if err != nil {
 			log.Println(err.Error())
 		}
-		log.Println(string(dump[:]))
+		log.Println(redactDump(dump))
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 
 	if debug {
 		log.Println("================ RESPONSE DUMP ==================")
@@ -278,23 +1758,196 @@ func doRequest(method, url, contentType string, reqBody io.Reader, bodySize int6
 		if err != nil {
 			log.Println(err.Error())
 		}
-		log.Println(string(dump[:]))
+		log.Println(redactDump(dump))
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer resp.Body.Close()
 
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, resp.Header, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return respBody, resp.Header, &githubError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       respBody,
+			RetryAfter: retryAfter(resp.Header),
+		}
+	}
+
+	return respBody, resp.Header, nil
+}
+
+// githubError is returned by doRequest when Github answers with an unsuccessful status code.
+// RetryAfter is set when Github asks to wait before trying again, e.g. when rate limiting.
+type githubError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+	RetryAfter time.Duration
+}
+
+// Error describes the error as reported by Github, listing the offending fields of a
+// rejected request. Bodies other than Github's usual error object are given as is.
+func (e *githubError) Error() string {
+	resp, ok := parseErrorBody(e.Body)
+	if !ok || resp.Message == "" {
+		return fmt.Sprintf("Github returned an error:\n Code: %s. \n Body: %s", e.Status, e.Body)
 	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return respBody, fmt.Errorf("Github returned an error:\n Code: %s. \n Body: %s", resp.Status, respBody)
+	msg := fmt.Sprintf("Github returned %s: %s", e.Status, resp.Message)
+	for _, detail := range resp.Errors {
+		msg += "\n - " + detail.describe()
 	}
+	return msg
+}
+
+// errorBody is the body of Github error responses, e.g. for a release which cannot be created:
+//
+//	{"message": "Validation Failed", "errors": [{"resource": "Release", "field": "tag_name", "code": "already_exists"}]}
+type errorBody struct {
+	Message string        `json:"message"`
+	Errors  []errorDetail `json:"errors"`
+}
 
-	return respBody, nil
+// errorDetail is an error about one field of a rejected request. Some are plain strings,
+// held by Message.
+type errorDetail struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+func (d *errorDetail) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &d.Message)
+	}
+	type detail errorDetail
+	return json.Unmarshal(data, (*detail)(d))
+}
+
+// errorCodes describe the codes of errorDetail.
+var errorCodes = map[string]string{
+	"missing":        "does not exist",
+	"missing_field":  "is required",
+	"invalid":        "is invalid",
+	"already_exists": "already exists",
+	"unprocessable":  "cannot be processed",
+}
+
+// describe returns a sentence like "Release tag_name already exists".
+func (d errorDetail) describe() string {
+	subject := strings.TrimSpace(d.Resource + " " + d.Field)
+	switch {
+	case d.Message != "" && subject != "":
+		return subject + ": " + d.Message
+	case d.Message != "":
+		return d.Message
+	case errorCodes[d.Code] != "":
+		return subject + " " + errorCodes[d.Code]
+	}
+	return strings.TrimSpace(subject + " " + d.Code)
+}
+
+// parseErrorBody decodes the body of a Github error response, reporting whether it is one.
+func parseErrorBody(data []byte) (errorBody, bool) {
+	var resp errorBody
+	err := json.Unmarshal(data, &resp)
+	return resp, err == nil
+}
+
+// retryAfter returns how long Github asks to wait before trying again, from the Retry-After
+// header or, when the rate limit is exhausted, from X-RateLimit-Reset. It is 0 otherwise.
+func retryAfter(header http.Header) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(value); err == nil && time.Until(t) > 0 {
+			return time.Until(t)
+		}
+	}
+
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if d := time.Until(time.Unix(reset, 0)); d > 0 {
+				return d
+			}
+		}
+	}
+	return 0
+}
+
+// retryDelay returns how long to wait before retrying after err: as long as Github asked
+// for, if it did, or else backoff.
+func retryDelay(err error, backoff time.Duration) time.Duration {
+	if t, ok := err.(*transientError); ok {
+		err = t.err
+	}
+	if ghErr, ok := err.(*githubError); ok && ghErr.RetryAfter > 0 {
+		return ghErr.RetryAfter
+	}
+	return backoff
+}
+
+// isRetryable tells whether a request that failed with err is worth sending again, i.e.
+// when no response was received or Github failed or asked to try again later. Other error
+// responses, like a 401 for a bad token, would only come back, as would a release found gone.
+func isRetryable(err error) bool {
+	if _, ok := err.(*releaseNotFoundError); ok {
+		return false
+	}
+	ghErr, ok := err.(*githubError)
+	if !ok {
+		return true
+	}
+	return ghErr.StatusCode >= http.StatusInternalServerError || ghErr.RetryAfter > 0
+}
+
+// isRateLimited tells whether err is an error response from Github asking to try again later.
+func isRateLimited(err error) bool {
+	ghErr, ok := err.(*githubError)
+	return ok && ghErr.RetryAfter > 0
+}
+
+// hasStatus tells whether err is an error response from Github with the given status code.
+func hasStatus(err error, code int) bool {
+	ghErr, ok := err.(*githubError)
+	return ok && ghErr.StatusCode == code
+}
+
+// explainAccessError replaces a 401, 403 or 404 from a repository-level endpoint, which is hardly
+// ever about the endpoint itself, with an error pointing at its likely causes.
+// Other errors are returned as is.
+func explainAccessError(err error, endpoint string) error {
+	var msg string
+	switch {
+	case hasStatus(err, http.StatusUnauthorized):
+		msg = fmt.Sprintf("Error: Github returned 401 Unauthorized for %s\n"+
+			"401 likely means the token is invalid, expired or revoked.", endpoint)
+	case hasStatus(err, http.StatusNotFound):
+		msg = fmt.Sprintf("Error: Github returned 404 Not Found for %s\n"+
+			"404 likely means the repository doesn't exist, is private and the token lacks access, "+
+			"or the token is missing the 'repo' scope.", endpoint)
+	case hasStatus(err, http.StatusForbidden):
+		msg = fmt.Sprintf("Error: Github returned 403 Forbidden for %s\n"+
+			"403 likely means the token lacks write access to the repository.", endpoint)
+	default:
+		return err
+	}
+
+	if verboseFlag {
+		log.Println(err)
+	}
+	if hint := ownerHint(); hint != "" {
+		msg += "\n" + hint
+	}
+	return errors.New(msg)
 }
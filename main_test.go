@@ -0,0 +1,199 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useTestServer points the Github API endpoint at a server running the handler for the
+// duration of the test, with requests timing out after timeout.
+func useTestServer(t *testing.T, timeout time.Duration, handler http.HandlerFunc) *httptest.Server {
+	srv := httptest.NewServer(handler)
+
+	endpoint, client, token := githubAPIEndpoint, httpClient, githubToken
+	githubAPIEndpoint = srv.URL + "/repos/o/r"
+	httpClient = &http.Client{Timeout: timeout}
+	githubToken = "secret"
+	t.Cleanup(func() {
+		srv.Close()
+		githubAPIEndpoint, httpClient, githubToken = endpoint, client, token
+	})
+	return srv
+}
+
+func TestCreateReleaseFindsDraftCreatedDespiteTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var posts int
+	var created []Release
+	release := make(chan struct{})
+	defer close(release)
+
+	useTestServer(t, 200*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/repos/o/r/releases":
+			var rel Release
+			data, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(data, &rel)
+			posts++
+			rel.ID = int64(posts)
+			now := time.Now()
+			rel.CreatedAt = &now
+			created = append(created, rel)
+			mu.Unlock()
+			// The release is created, but the response comes too late.
+			<-release
+			return
+		case r.URL.Path == "/repos/o/r/releases/tags/v1":
+			// Github does not find drafts by tag.
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		case r.URL.Path == "/repos/o/r/releases":
+			json.NewEncoder(w).Encode(created)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		mu.Unlock()
+	})
+
+	retries := createRetriesFlag
+	createRetriesFlag = 2
+	defer func() { createRetriesFlag = retries }()
+
+	data, err := createRelease(Release{TagName: "v1", Draft: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Release
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 1 || !got.Draft {
+		t.Errorf("got release %+v, want the draft created by the first request", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if posts != 1 {
+		t.Errorf("release was created %d times, want once", posts)
+	}
+}
+
+func TestCreateReleaseDoesNotTakeOlderReleaseForCreated(t *testing.T) {
+	older := time.Now().Add(-time.Hour)
+	var mu sync.Mutex
+	var posts int
+	useTestServer(t, 5*time.Second, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/repos/o/r/releases":
+			posts++
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"message":"Server Error"}`))
+		case r.URL.Path == "/repos/o/r/releases/tags/v1":
+			json.NewEncoder(w).Encode(Release{ID: 7, TagName: "v1", CreatedAt: &older})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	captureOutput(t)
+	defer func(retries int, noReuse bool) {
+		createRetriesFlag, noReuseFlag = retries, noReuse
+	}(createRetriesFlag, noReuseFlag)
+	createRetriesFlag = 2
+
+	noReuseFlag = true
+	if data, err := createRelease(Release{TagName: "v1"}); err == nil {
+		t.Errorf("createRelease() under -no-reuse = %s, want an error", data)
+	}
+	mu.Lock()
+	if posts != 1 {
+		t.Errorf("release creation was sent %d times, want once", posts)
+	}
+	posts = 0
+	mu.Unlock()
+
+	noReuseFlag = false
+	data, err := createRelease(Release{TagName: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Release
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 7 {
+		t.Errorf("got release %+v, want the existing one reused", got)
+	}
+}
+
+func TestTransportConnectTimeout(t *testing.T) {
+	client := &http.Client{Transport: newTransport(200 * time.Millisecond)}
+
+	// Nothing answers on this non-routable address, so connecting hangs until timed out.
+	start := time.Now()
+	_, err := client.Get("http://10.255.255.1/")
+	elapsed := time.Since(start)
+
+	netErr, ok := err.(net.Error)
+	if err == nil || (ok && !netErr.Timeout()) {
+		t.Skipf("the address is not black-holed in this environment: %v", err)
+	}
+	if !ok {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("connecting gave up after %s, want about 200ms", elapsed)
+	}
+}
+
+func TestReleaseArgsRejectsBranchAlongWithTarget(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.tgz")
+	if err := ioutil.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(target string) { targetFlag = target }(targetFlag)
+
+	cases := []struct {
+		target string
+		args   []string
+		want   []string
+	}{
+		{"", []string{"o/r", "v1", "main", "notes", file}, []string{"o/r", "v1", "main", "notes", file}},
+		{"", []string{"o/r", "v1", "notes", file}, []string{"o/r", "v1", "", "notes", file}},
+		{"main", []string{"o/r", "v1", "notes", file}, []string{"o/r", "v1", "main", "notes", file}},
+		{"main", []string{"o/r", "v1", "notes", file, dir + "/*.zip"}, []string{"o/r", "v1", "main", "notes", file, dir + "/*.zip"}},
+		{"main", []string{"o/r", "v1", "notes", file + "#Linux", file}, []string{"o/r", "v1", "main", "notes", file + "#Linux", file}},
+		{"main", []string{"o/r", "v1", "main", "notes", dir + "/*"}, nil},
+		{"main", []string{"o/r", "v1", "dev", "notes", file, file}, nil},
+	}
+	for _, c := range cases {
+		targetFlag = c.target
+		got, err := releaseArgs(c.args)
+		if c.want == nil {
+			if err == nil {
+				t.Errorf("releaseArgs(%q) under -target %s = %q, want an error", c.args, c.target, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("releaseArgs(%q) under -target %s failed: %s", c.args, c.target, err)
+		} else if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("releaseArgs(%q) under -target %s = %q, want %q", c.args, c.target, got, c.want)
+		}
+	}
+}
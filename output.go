@@ -0,0 +1,80 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Messages meant for humans are logged to stderr, leaving stdout to the machine readable
+// output requested with -json, -events-file -, -export-env or -diff, so that it can be
+// piped as is.
+
+// stdout receives the machine readable output.
+var stdout io.Writer = os.Stdout
+
+// printJSON writes v to stdout as a line of JSON.
+func printJSON(v interface{}) error {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, string(out))
+	return err
+}
+
+// printExports writes to stdout shell export lines describing the release, to be evaluated
+// by the calling script.
+func printExports(release Release) error {
+	exports := []struct{ name, value string }{
+		{"RELEASE_ID", strconv.FormatInt(release.ID, 10)},
+		{"RELEASE_HTML_URL", release.HTMLURL},
+		{"RELEASE_UPLOAD_URL", release.UploadURL},
+	}
+	for _, e := range exports {
+		if _, err := fmt.Fprintf(stdout, "export %s=%s\n", e.name, shellQuote(e.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s so that a POSIX shell reads it as a single literal word.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// printRelease writes to stdout the release as it ends up, assets included, as indented
+// JSON. Under -timings, the timing breakdown is added to it.
+func printRelease(release Release) error {
+	final, err := getRelease(release.ID)
+	if err != nil {
+		return err
+	}
+	if final.Assets, err = listAssets(final); err != nil {
+		return err
+	}
+
+	out := struct {
+		Release
+		Timings *timingsReport `json:"timings,omitempty"`
+	}{Release: final}
+	if timingsFlag {
+		report := timings.report()
+		out.Timings = &report
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, string(data))
+	return err
+}
@@ -0,0 +1,131 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// captureOutput collects what is written to stdout and stderr for the duration of the test.
+func captureOutput(t *testing.T) (out, errOut *bytes.Buffer) {
+	out, errOut = &bytes.Buffer{}, &bytes.Buffer{}
+	stdout = out
+	log.SetOutput(errOut)
+	t.Cleanup(func() {
+		stdout = os.Stdout
+		log.SetOutput(os.Stderr)
+	})
+	return out, errOut
+}
+
+func TestPrintExportsOnlyWritesStdout(t *testing.T) {
+	out, errOut := captureOutput(t)
+
+	release := Release{ID: 42, HTMLURL: "https://github.com/o/r/releases/tag/v1", UploadURL: "https://uploads/it's"}
+	if err := printExports(release); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "export RELEASE_ID='42'\n" +
+		"export RELEASE_HTML_URL='https://github.com/o/r/releases/tag/v1'\n" +
+		"export RELEASE_UPLOAD_URL='https://uploads/it'\\''s'\n"
+	if out.String() != want {
+		t.Errorf("stdout = %q, want %q", out, want)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("stderr = %q, want nothing", errOut)
+	}
+}
+
+func TestProgressOnlyWritesStderr(t *testing.T) {
+	out, errOut := captureOutput(t)
+
+	infof("Uploading %s...\n", "a")
+	if out.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing", out)
+	}
+	if errOut.String() != "Uploading a...\n" {
+		t.Errorf("stderr = %q, want the progress message", errOut)
+	}
+
+	errOut.Reset()
+	level := logLevel
+	logLevel = levelError
+	defer func() { logLevel = level }()
+	infof("Uploading %s...\n", "a")
+	logf(levelError, "Error: %s\n", "boom")
+	if errOut.String() != "Error: boom\n" {
+		t.Errorf("stderr under -quiet = %q, want the error only", errOut)
+	}
+}
+
+func TestExistsJSONOnlyWritesStdout(t *testing.T) {
+	useTestServer(t, 5*time.Second, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/releases/tags/v1":
+			w.Write([]byte(`{"id":1,"tag_name":"v1"}`))
+		case "/repos/o/r/releases":
+			w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		}
+	})
+	out, errOut := captureOutput(t)
+	jsonFlag = true
+	defer func() { jsonFlag = false }()
+
+	if code := releaseExists("v1"); code != 0 {
+		t.Errorf("exit status = %d, want 0", code)
+	}
+	if out.String() != "{\"exists\":true}\n" {
+		t.Errorf("stdout = %q, want the JSON result", out)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("stderr = %q, want nothing", errOut)
+	}
+
+	out.Reset()
+	if code := releaseExists("v2"); code != exitNotFound {
+		t.Errorf("exit status = %d, want %d", code, exitNotFound)
+	}
+	if out.String() != "{\"exists\":false}\n" {
+		t.Errorf("stdout = %q, want the JSON result", out)
+	}
+}
+
+func TestDeleteIsQuiet(t *testing.T) {
+	useTestServer(t, 5*time.Second, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/o/r/releases/tags/v1":
+			w.Write([]byte(`{"id":1,"tag_name":"v1"}`))
+		case r.URL.Path == "/repos/o/r/releases" && r.Method == "GET":
+			w.Write([]byte(`[]`))
+		case r.URL.Path == "/repos/o/r/releases/1" && r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		}
+	})
+	_, errOut := captureOutput(t)
+	level := logLevel
+	logLevel = levelError
+	defer func() { logLevel = level }()
+
+	for _, tag := range []string{"v1", "v2"} {
+		if err := deleteRelease(tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("stderr under -quiet = %q, want nothing", errOut)
+	}
+}
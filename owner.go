@@ -0,0 +1,61 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Values of -owner-type.
+const (
+	ownerOrg  = "org"
+	ownerUser = "user"
+)
+
+// ownerType returns whether the repository owner is an organization or a user,
+// as given by -owner-type or else as reported by Github. An empty string is
+// returned if it cannot be told.
+func ownerType() string {
+	if ownerTypeFlag != "" {
+		return ownerTypeFlag
+	}
+
+	// Only a hint is at stake, so the lookup is not retried.
+	endpoint := fmt.Sprintf("%s/users/%s", githubAPIRoot, githubUser)
+	data, err := doRequest("GET", endpoint, "application/json", nil, int64(0))
+	if err != nil {
+		return ""
+	}
+
+	var owner struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return ""
+	}
+
+	switch owner.Type {
+	case "Organization":
+		return ownerOrg
+	case "User":
+		return ownerUser
+	}
+	return ""
+}
+
+// ownerHint returns advice on permission failures suited to the repository owner.
+// It is purely advisory and empty when the owner type is unknown.
+func ownerHint() string {
+	switch ownerType() {
+	case ownerOrg:
+		return fmt.Sprintf("%s is an organization: the token needs write access to the repository, "+
+			"and if %s enforces SAML single sign-on, your token may lack org SSO authorization.", githubUser, githubUser)
+	case ownerUser:
+		return fmt.Sprintf("%s is a user: the token must belong to %s or to a collaborator with write access, "+
+			"and have the 'repo' scope, or 'public_repo' for a public repository.", githubUser, githubUser)
+	}
+	return ""
+}
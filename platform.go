@@ -0,0 +1,107 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// platformOS and platformArch map the operating system and architecture tokens found in
+// asset names, goreleaser style, to their human names.
+var platformOS = map[string]string{
+	"linux":   "Linux",
+	"darwin":  "macOS",
+	"macos":   "macOS",
+	"windows": "Windows",
+	"freebsd": "FreeBSD",
+	"openbsd": "OpenBSD",
+	"netbsd":  "NetBSD",
+	"android": "Android",
+	"illumos": "illumos",
+	"solaris": "Solaris",
+}
+
+var platformArch = map[string]string{
+	"amd64":   "x86-64",
+	"x86_64":  "x86-64",
+	"x64":     "x86-64",
+	"386":     "x86",
+	"i386":    "x86",
+	"i686":    "x86",
+	"arm64":   "ARM64",
+	"aarch64": "ARM64",
+	"arm":     "ARM",
+	"armv6":   "ARMv6",
+	"armv7":   "ARMv7",
+	"ppc64le": "PowerPC 64 LE",
+	"s390x":   "s390x",
+	"riscv64": "RISC-V 64",
+	"all":     "Universal",
+}
+
+var platformSeparators = regexp.MustCompile(`[-_.]`)
+
+// platformLabel returns a label like "Linux x86-64" for an asset named after its
+// platform, e.g. myapp_1.2.3_linux_amd64.tar.gz, or an empty string otherwise.
+func platformLabel(name string) string {
+	tokens := platformSeparators.Split(strings.ToLower(name), -1)
+	for i := 0; i+1 < len(tokens); i++ {
+		osName, ok := platformOS[tokens[i]]
+		if !ok {
+			continue
+		}
+
+		// Architectures like x86_64 span two tokens.
+		if i+2 < len(tokens) {
+			if arch, ok := platformArch[tokens[i+1]+"_"+tokens[i+2]]; ok {
+				return osName + " " + arch
+			}
+		}
+		if arch, ok := platformArch[tokens[i+1]]; ok {
+			return osName + " " + arch
+		}
+	}
+	return ""
+}
+
+// loadPlatformRules adds to the known platform tokens the ones of the given file, which
+// has one os:token=Name or arch:token=Name rule per line. Empty lines and lines starting
+// with # are ignored.
+func loadPlatformRules(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		kind := strings.SplitN(parts[0], ":", 2)
+		if len(parts) != 2 || len(kind) != 2 || strings.TrimSpace(kind[1]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return fmt.Errorf("Error: %s:%d: Expected os:token=Name or arch:token=Name, got %q", path, lineno, line)
+		}
+
+		token := strings.ToLower(strings.TrimSpace(kind[1]))
+		switch strings.TrimSpace(kind[0]) {
+		case "os":
+			platformOS[token] = strings.TrimSpace(parts[1])
+		case "arch":
+			platformArch[token] = strings.TrimSpace(parts[1])
+		default:
+			return fmt.Errorf("Error: %s:%d: Unknown kind of platform token: %s", path, lineno, kind[0])
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,52 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// progressInterval is how often the progress of an upload is logged.
+const progressInterval = 5 * time.Second
+
+// progressReader logs how much of an asset has been read from r, every progressInterval
+// at most, so that big uploads and downloads don't look stuck.
+type progressReader struct {
+	r      io.Reader
+	action string
+	name   string
+	size   int64
+	read   int64
+	last   time.Time
+}
+
+// newProgressReader returns a reader logging the progress of the action, e.g. "Uploading",
+// on the named asset of the given size.
+func newProgressReader(r io.Reader, action, name string, size int64) *progressReader {
+	return &progressReader{r: r, action: action, name: name, size: size, last: time.Now()}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if r.read < r.size && time.Since(r.last) >= progressInterval {
+		r.last = time.Now()
+		assetLogf(levelInfo, r.name, "%s %s: %d%% (%s of %s)\n",
+			r.action, r.name, r.read*100/r.size, humanSize(r.read), humanSize(r.size))
+	}
+	return n, err
+}
+
+// showProgress tells whether the progress of transfers is worth logging, i.e. when someone
+// is likely watching the terminal messages are logged to, and not under -quiet.
+func showProgress() bool {
+	if logLevel > levelInfo {
+		return false
+	}
+	stat, err := os.Stderr.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
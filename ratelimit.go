@@ -0,0 +1,75 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket over bytes, shared by all the readers it limits so
+// that their combined rate stays under the configured number of bytes per second.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+// uploadLimiter limits the bandwidth of all uploads under -max-upload-rate, if set.
+var uploadLimiter *rateLimiter
+
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{rate: rate, last: time.Now()}
+}
+
+// wait blocks until n bytes can be sent without exceeding the rate.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if l.tokens > float64(l.rate) {
+		// Don't let idle time build up more than a second worth of burst.
+		l.tokens = float64(l.rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	debt := l.tokens
+	l.mu.Unlock()
+
+	if debt < 0 {
+		time.Sleep(time.Duration(-debt / float64(l.rate) * float64(time.Second)))
+	}
+}
+
+// chunk returns the largest number of bytes worth reading at once.
+func (l *rateLimiter) chunk() int {
+	n := l.rate / 10
+	if n < 1 {
+		n = 1
+	}
+	if n > 32*1024 {
+		n = 32 * 1024
+	}
+	return int(n)
+}
+
+// rateLimitedReader reads from r no faster than its limiter allows.
+type rateLimitedReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.chunk() {
+		p = p[:r.limiter.chunk()]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
@@ -0,0 +1,61 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedReadersStayNearRate(t *testing.T) {
+	const rate = 200 * 1024
+	for _, readers := range []int{1, 4} {
+		limiter := newRateLimiter(rate)
+		const total = rate / 2
+		start := time.Now()
+
+		var wg sync.WaitGroup
+		for i := 0; i < readers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r := &rateLimitedReader{r: bytes.NewReader(make([]byte, total/readers)), limiter: limiter}
+				if _, err := io.Copy(ioutil.Discard, r); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		elapsed := time.Since(start)
+		effective := float64(total) / elapsed.Seconds()
+		if effective > rate*1.1 {
+			t.Errorf("%d reader(s) read at %.0f bytes/s, above the %d bytes/s cap", readers, effective, rate)
+		}
+		if effective < rate*0.6 {
+			t.Errorf("%d reader(s) read at %.0f bytes/s, far below the %d bytes/s cap", readers, effective, rate)
+		}
+	}
+}
+
+func TestRateLimiterChunk(t *testing.T) {
+	tests := []struct {
+		rate  int64
+		chunk int
+	}{
+		{1, 1},
+		{1000, 100},
+		{1 << 30, 32 * 1024},
+	}
+	for _, tt := range tests {
+		if got := newRateLimiter(tt.rate).chunk(); got != tt.chunk {
+			t.Errorf("chunk at %d bytes/s = %d, want %d", tt.rate, got, tt.chunk)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// signatureExtensions are the extensions of detached signatures which, like checksum
+// manifests, describe the other assets of a release rather than being artifacts.
+var signatureExtensions = []string{".asc", ".sig", ".minisig"}
+
+// isMetadataAsset tells whether the asset is a checksum manifest or a signature.
+func isMetadataAsset(name string) bool {
+	for _, ext := range signatureExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+
+	for algorithm := range hashAlgorithms {
+		base := strings.ToUpper(algorithm) + "SUMS"
+		for _, suffix := range []string{"", "." + manifestJSON, "." + manifestCSV} {
+			if name == base+suffix {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// refreshSignatures replaces the checksum manifests and signatures of the release for tag,
+// leaving its artifacts untouched. Signatures are taken from the given files, while the
+// other files are local copies of artifacts sparing their download when checksums are
+// regenerated under -checksums.
+func refreshSignatures(tag string, files []assetFile) {
+	release, err := findReleaseByTag(tag)
+	if err != nil {
+		fatalln(err)
+	}
+
+	var metadata []assetFile
+	copies := make(map[string]assetFile)
+	for _, f := range files {
+		if isMetadataAsset(f.name) {
+			metadata = append(metadata, f)
+		} else {
+			copies[f.name] = f
+		}
+	}
+
+	if checksumsFlag {
+		tmpDir, err := newTempDir()
+		if err != nil {
+			fatalln(err)
+		}
+
+		artifacts, err := releaseArtifacts(release, copies, tmpDir)
+		if err != nil {
+			fatalln(err)
+		}
+
+		dir := manifestDirFlag
+		if dir == "" {
+			dir = filepath.Join(tmpDir, "manifests")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				fatalln(err)
+			}
+		}
+		manifests, err := generateManifests(dir, artifacts)
+		if err != nil {
+			fatalln(err)
+		}
+		metadata = append(metadata, newAssetFiles(manifests)...)
+	}
+
+	if len(metadata) == 0 {
+		fatal("Error: Nothing to refresh, give signature files or -checksums")
+	}
+
+	// Existing manifests and signatures are deleted before being uploaded again.
+	replaceFlag = true
+	if err := uploadFiles(release, metadata); err != nil {
+		log.Println(err)
+		exit(exitUploadFailed)
+	}
+}
+
+// releaseArtifacts returns local files holding the artifacts of the release, using the
+// given copies when they have the size of the asset and downloading the others into dir.
+func releaseArtifacts(release Release, copies map[string]assetFile, dir string) ([]assetFile, error) {
+	assets, err := listAssets(release)
+	if err != nil {
+		return nil, err
+	}
+
+	var artifacts []assetFile
+	for _, a := range assets {
+		if isMetadataAsset(a.Name) {
+			continue
+		}
+		if f, ok := copies[a.Name]; ok && localSize(f.path) == a.Size {
+			artifacts = append(artifacts, f)
+			continue
+		}
+
+		infof("Downloading %s...\n", a.Name)
+		path := filepath.Join(dir, a.Name)
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		err = downloadAsset(a, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, assetFile{path: path, name: a.Name})
+	}
+	return artifacts, nil
+}
@@ -0,0 +1,282 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// lookup sends a GET request to the endpoint, retrying with exponential backoff up to
+// -metadata-retries times when no response or a server error was received, or after the
+// delay Github asked for when rate limited.
+func lookup(endpoint string) ([]byte, http.Header, error) {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		data, header, err := doRequestWithHeader("GET", endpoint, "application/json", nil, int64(0))
+		if err == nil || !isRetryable(err) || attempt >= metadataRetriesFlag {
+			return data, header, err
+		}
+
+		delay := retryDelay(err, backoff)
+		log.Printf("Error: %s\n", err)
+		infof("Retrying lookup of %s in %s...\n", endpoint, delay.Round(time.Second))
+		time.Sleep(delay)
+		backoff *= 2
+	}
+}
+
+// listReleases returns all the releases of the repository, following Github's pagination.
+func listReleases() ([]Release, error) {
+	raw, err := lookupReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]Release, len(raw))
+	for i := range raw {
+		if err := json.Unmarshal(raw[i], &releases[i]); err != nil {
+			return nil, err
+		}
+	}
+	return releases, nil
+}
+
+// lookupReleases returns all the releases of the repository as Github describes them,
+// following its pagination.
+func lookupReleases() ([]json.RawMessage, error) {
+	releases := []json.RawMessage{}
+	endpoint := fmt.Sprintf("%s/releases?per_page=100", githubAPIEndpoint)
+	for endpoint != "" {
+		data, header, err := lookup(endpoint)
+		if err != nil {
+			return nil, explainAccessError(err, endpoint)
+		}
+
+		var page []json.RawMessage
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		releases = append(releases, page...)
+		endpoint = nextPageURL(header)
+	}
+	return releases, nil
+}
+
+// getRelease looks up a release by ID.
+func getRelease(id int64) (Release, error) {
+	var release Release
+	endpoint := fmt.Sprintf("%s/releases/%d", githubAPIEndpoint, id)
+	data, _, err := lookup(endpoint)
+	if err != nil {
+		return release, err
+	}
+
+	err = json.Unmarshal(data, &release)
+	return release, err
+}
+
+// getReleaseByTag looks up the published release for the given tag.
+func getReleaseByTag(tag string) (Release, error) {
+	var release Release
+	endpoint := fmt.Sprintf("%s/releases/tags/%s", githubAPIEndpoint, tag)
+	data, _, err := lookup(endpoint)
+	if err != nil {
+		return release, err
+	}
+
+	err = json.Unmarshal(data, &release)
+	return release, err
+}
+
+// findReleaseByTag looks up the release for the given tag, including drafts,
+// which Github does not return when looking up releases by tag.
+func findReleaseByTag(tag string) (Release, error) {
+	release, err := getReleaseByTag(tag)
+	if err == nil {
+		return release, nil
+	}
+
+	releases, listErr := listReleases()
+	if listErr != nil {
+		return release, listErr
+	}
+	for _, r := range releases {
+		if r.TagName == tag {
+			return r, nil
+		}
+	}
+	return release, err
+}
+
+// tagExists tells whether the tag exists in the repository, with or without a release.
+func tagExists(tag string) (bool, error) {
+	endpoint := fmt.Sprintf("%s/git/ref/tags/%s", githubAPIEndpoint, tag)
+	_, _, err := lookup(endpoint)
+	if hasStatus(err, http.StatusNotFound) {
+		return false, nil
+	}
+	return err == nil, explainAccessError(err, endpoint)
+}
+
+// tagAnnotation returns the message of the annotated tag, or an empty string if the tag
+// does not exist or is a lightweight tag.
+func tagAnnotation(tag string) (string, error) {
+	endpoint := fmt.Sprintf("%s/git/ref/tags/%s", githubAPIEndpoint, tag)
+	data, _, err := lookup(endpoint)
+	if hasStatus(err, http.StatusNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", explainAccessError(err, endpoint)
+	}
+
+	var ref struct {
+		Object struct {
+			Type string `json:"type"`
+			SHA  string `json:"sha"`
+		} `json:"object"`
+	}
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return "", err
+	}
+	if ref.Object.Type != "tag" {
+		return "", nil
+	}
+
+	data, _, err = lookup(fmt.Sprintf("%s/git/tags/%s", githubAPIEndpoint, ref.Object.SHA))
+	if err != nil {
+		return "", err
+	}
+
+	var annotated struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &annotated); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(annotated.Message), nil
+}
+
+// omitExistingTagTarget clears the target of the release when its tag already exists,
+// as the target is only used by Github to create the tag and can otherwise be rejected
+// or move the tag somewhere unexpected.
+func omitExistingTagTarget(release *Release) error {
+	if release.Branch == "" {
+		return nil
+	}
+
+	exists, err := tagExists(release.TagName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if debug {
+			log.Printf("Tag %s already exists, ignoring target %s.\n", release.TagName, release.Branch)
+		}
+		release.Branch = ""
+	}
+	return nil
+}
+
+// listAssets returns all the assets of a release, following Github's pagination. The assets
+// embedded in a release by Github are not all there when the release has many of them.
+func listAssets(release Release) ([]Asset, error) {
+	var assets []Asset
+	endpoint := fmt.Sprintf("%s/releases/%d/assets?per_page=100", githubAPIEndpoint, release.ID)
+	for endpoint != "" {
+		data, header, err := lookup(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []Asset
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		assets = append(assets, page...)
+		endpoint = nextPageURL(header)
+	}
+	return assets, nil
+}
+
+// downloadAsset writes the content of a release asset to w.
+func downloadAsset(asset Asset, w io.Writer) error {
+	req, err := http.NewRequest("GET", asset.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", githubToken))
+	req.Header.Set("Accept", "application/octet-stream")
+	setAPIVersion(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Github returned an error downloading %s:\n Code: %s.", asset.Name, resp.Status)
+	}
+
+	var body io.Reader = resp.Body
+	if asset.Size > 0 && showProgress() {
+		body = newProgressReader(body, "Downloading", asset.Name, asset.Size)
+	}
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// findDraftByName looks up the draft release with the given name. It is an error
+// if no draft or more than one draft carries that name.
+func findDraftByName(name string) (Release, error) {
+	releases, err := listReleases()
+	if err != nil {
+		return Release{}, err
+	}
+
+	var drafts []Release
+	for _, r := range releases {
+		if r.Draft && r.Name == name {
+			drafts = append(drafts, r)
+		}
+	}
+
+	switch len(drafts) {
+	case 0:
+		return Release{}, fmt.Errorf("Error: No draft release named %q was found", name)
+	case 1:
+		return drafts[0], nil
+	}
+
+	candidates := make([]string, len(drafts))
+	for i, d := range drafts {
+		candidates[i] = fmt.Sprintf("  id: %d, tag: %q", d.ID, d.TagName)
+	}
+	return Release{}, fmt.Errorf("Error: Found %d draft releases named %q:\n%s", len(drafts), name, strings.Join(candidates, "\n"))
+}
+
+// nextPageURL extracts the URL of the next page from a Github Link header, e.g.:
+// <https://api.github.com/repositories/1/releases?page=2>; rel="next", <...>; rel="last"
+func nextPageURL(header http.Header) string {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFindReleaseByTagReportsListingError(t *testing.T) {
+	useTestServer(t, 5*time.Second, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/releases":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message":"Server Error"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		}
+	})
+	defer func(retries int) { metadataRetriesFlag = retries }(metadataRetriesFlag)
+	metadataRetriesFlag = 0
+	captureOutput(t)
+
+	_, err := findReleaseByTag("v1")
+	if !hasStatus(err, http.StatusInternalServerError) {
+		t.Fatalf("findReleaseByTag() error = %v, want the listing's 500", err)
+	}
+	if code := releaseExists("v1"); code != 1 {
+		t.Errorf("releaseExists() = %d, want 1", code)
+	}
+	if err := deleteRelease("v1"); err == nil {
+		t.Error("deleteRelease() succeeded, want the listing error")
+	}
+}
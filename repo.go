@@ -0,0 +1,42 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Github owners are made of alphanumeric characters and single hyphens, and cannot
+	// start or end with a hyphen.
+	ownerPattern = regexp.MustCompile(`^[A-Za-z0-9](?:-?[A-Za-z0-9])*$`)
+	repoPattern  = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// parseRepo splits a <user/repo> argument into its owner and repository, also accepting
+// the URL of the repository as copied from Github, e.g. https://github.com/owner/repo.git.
+func parseRepo(s string) (owner, repo string, err error) {
+	name := strings.TrimSpace(s)
+	for _, prefix := range []string{"https://", "http://", "www.", "github.com/"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "/"), ".git")
+
+	parts := strings.Split(name, "/")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("Error: Invalid format used for username and repository: %s", s)
+	}
+	owner, repo = parts[0], parts[1]
+
+	if len(owner) > 39 || !ownerPattern.MatchString(owner) {
+		return "", "", fmt.Errorf("Error: Invalid Github user or organization name: %s", owner)
+	}
+	if len(repo) > 100 || !repoPattern.MatchString(repo) || repo == "." || repo == ".." {
+		return "", "", fmt.Errorf("Error: Invalid Github repository name: %s", repo)
+	}
+	return owner, repo, nil
+}
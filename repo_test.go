@@ -0,0 +1,59 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import "testing"
+
+func TestParseRepo(t *testing.T) {
+	tests := []struct {
+		in    string
+		owner string
+		repo  string
+	}{
+		{"octocat/hello-world", "octocat", "hello-world"},
+		{" octocat/hello-world ", "octocat", "hello-world"},
+		{"octocat/hello-world.git", "octocat", "hello-world"},
+		{"octocat/hello.world", "octocat", "hello.world"},
+		{"octo-cat/_repo", "octo-cat", "_repo"},
+		{"github.com/octocat/hello-world", "octocat", "hello-world"},
+		{"https://github.com/octocat/hello-world", "octocat", "hello-world"},
+		{"https://github.com/octocat/hello-world/", "octocat", "hello-world"},
+		{"https://github.com/octocat/hello-world.git", "octocat", "hello-world"},
+		{"http://www.github.com/octocat/hello-world.git", "octocat", "hello-world"},
+	}
+	for _, tt := range tests {
+		owner, repo, err := parseRepo(tt.in)
+		if err != nil {
+			t.Errorf("parseRepo(%q) failed: %s", tt.in, err)
+			continue
+		}
+		if owner != tt.owner || repo != tt.repo {
+			t.Errorf("parseRepo(%q) = %s, %s, want %s, %s", tt.in, owner, repo, tt.owner, tt.repo)
+		}
+	}
+}
+
+func TestParseRepoRejects(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"octocat",
+		"octocat/",
+		"/hello-world",
+		"octocat/hello-world/releases",
+		"https://github.com/octocat",
+		"-octocat/hello-world",
+		"octocat-/hello-world",
+		"octo--cat/hello-world",
+		"octo_cat/hello-world",
+		"octocat/hello world",
+		"octocat/..",
+		"octocat/.",
+		"a234567890123456789012345678901234567890/repo",
+	} {
+		if owner, repo, err := parseRepo(in); err == nil {
+			t.Errorf("parseRepo(%q) = %s, %s, want an error", in, owner, repo)
+		}
+	}
+}
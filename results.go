@@ -0,0 +1,147 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Statuses of an asset once its upload is over.
+const (
+	statusUploaded = "uploaded"
+	statusSkipped  = "skipped"
+	statusFailed   = "failed"
+)
+
+// assetResult is the outcome of uploading one asset.
+type assetResult struct {
+	Asset  string `json:"asset"`
+	Status string `json:"status"`
+	Size   int64  `json:"size"`
+	MS     int64  `json:"ms"`
+	Error  string `json:"error,omitempty"`
+}
+
+// uploadResults collects the outcome of every asset upload, optionally
+// streaming each of them as a line of JSON as soon as it is known.
+type uploadResults struct {
+	mu      sync.Mutex
+	results []assetResult
+	events  io.Writer
+	closer  io.Closer
+}
+
+// newUploadResults creates a collector writing events to the given file, which is
+// appended to. An empty path disables events, while - writes them to stdout.
+func newUploadResults(eventsPath string) (*uploadResults, error) {
+	r := &uploadResults{}
+	switch eventsPath {
+	case "":
+	case "-":
+		r.events = stdout
+	default:
+		file, err := os.OpenFile(eventsPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		r.events = file
+		r.closer = file
+	}
+	return r, nil
+}
+
+// Record stores the outcome of an asset upload and emits its event.
+func (r *uploadResults) Record(name string, size int64, uploaded bool, err error, elapsed time.Duration) {
+	result := assetResult{
+		Asset:  name,
+		Status: statusSkipped,
+		Size:   size,
+		MS:     int64(elapsed / time.Millisecond),
+	}
+	switch {
+	case err != nil:
+		result.Status = statusFailed
+		result.Error = err.Error()
+	case uploaded:
+		result.Status = statusUploaded
+	}
+
+	if elapsed > 0 {
+		timings.TrackAsset(name, elapsed)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = append(r.results, result)
+	if r.events == nil {
+		return
+	}
+
+	line, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+	if _, err := r.events.Write(append(line, '\n')); err != nil {
+		log.Printf("Error: Unable to write upload event: %s\n", err)
+	}
+}
+
+// Results returns the outcome of the asset uploads recorded so far.
+func (r *uploadResults) Results() []assetResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]assetResult{}, r.results...)
+}
+
+// Failures returns the outcome of the asset uploads that failed.
+func (r *uploadResults) Failures() []assetResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var failures []assetResult
+	for _, result := range r.results {
+		if result.Status == statusFailed {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// Summarize logs the outcome of every asset upload.
+func (r *uploadResults) Summarize() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.results) == 0 {
+		return
+	}
+
+	infof("Summary:\n")
+	for _, result := range r.results {
+		infof("  %-9s%s (%d bytes, %s)\n", result.Status, result.Asset, result.Size, time.Duration(result.MS)*time.Millisecond)
+	}
+}
+
+// Close releases the events file, if any.
+func (r *uploadResults) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closer == nil {
+		return nil
+	}
+	err := r.closer.Close()
+	r.closer = nil
+	r.events = nil
+	return err
+}
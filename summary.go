@@ -0,0 +1,54 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// stepSummaryEnv names the file in which Github Actions expects the Markdown summary of a step.
+const stepSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// writeStepSummary appends to the Github Actions step summary a report of the release
+// and of its assets. Nothing is written outside of Github Actions.
+func writeStepSummary(release Release, results *uploadResults) error {
+	path := os.Getenv(stepSummaryEnv)
+	if path == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	name := release.Name
+	if name == "" {
+		name = release.TagName
+	}
+	if release.HTMLURL != "" {
+		fmt.Fprintf(&buf, "### Release [%s](%s)\n\n", name, release.HTMLURL)
+	} else {
+		fmt.Fprintf(&buf, "### Release %s\n\n", name)
+	}
+
+	if assets := results.Results(); len(assets) > 0 {
+		buf.WriteString("| Asset | Size | Status |\n| --- | ---: | --- |\n")
+		for _, r := range assets {
+			name := strings.Replace(r.Asset, "|", "\\|", -1)
+			fmt.Fprintf(&buf, "| %s | %s | %s |\n", name, humanSize(r.Size), r.Status)
+		}
+		buf.WriteString("\n")
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(buf.Bytes())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// createAnnotatedTag creates the tag as an annotated tag with the given message, pointing at
+// the commit the target resolves to, or at the head of the default branch without target.
+// Github would otherwise create a lightweight tag along with the release. A tag which
+// already exists is left alone.
+func createAnnotatedTag(tag, target, message string) error {
+	exists, err := tagExists(tag)
+	if err != nil {
+		return err
+	}
+	if exists {
+		infof("Tag %s already exists, not creating it.\n", tag)
+		return nil
+	}
+
+	if target == "" {
+		target = "HEAD"
+	}
+	sha, err := resolveCommit(target)
+	if err != nil {
+		return err
+	}
+
+	tagData, err := json.Marshal(map[string]string{
+		"tag":     tag,
+		"message": message,
+		"object":  sha,
+		"type":    "commit",
+	})
+	if err != nil {
+		return err
+	}
+	data, err := doRequest("POST", fmt.Sprintf("%s/git/tags", githubAPIEndpoint), "application/json", bytes.NewReader(tagData), int64(len(tagData)))
+	if err != nil {
+		return fmt.Errorf("Error: Unable to create tag %s: %s", tag, err)
+	}
+
+	var object struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+
+	refData, err := json.Marshal(map[string]string{
+		"ref": "refs/tags/" + tag,
+		"sha": object.SHA,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = doRequest("POST", fmt.Sprintf("%s/git/refs", githubAPIEndpoint), "application/json", bytes.NewReader(refData), int64(len(refData)))
+	if hasStatus(err, http.StatusUnprocessableEntity) {
+		// The tag may have been pushed since it was looked up.
+		if exists, _ := tagExists(tag); exists {
+			infof("Tag %s was created in the meantime, not creating it.\n", tag)
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Error: Unable to create tag %s: %s", tag, err)
+	}
+	infof("Created annotated tag %s on commit %s.\n", tag, sha)
+	return nil
+}
@@ -0,0 +1,172 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// walkTar calls fn for every regular file of the tar archive at tarPath, gunzipping it
+// first if its extension says so. fn must consume the entry before returning.
+func walkTar(tarPath string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(tarPath, ".gz") || strings.HasSuffix(tarPath, ".tgz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("Error: Unable to decompress %s: %s", tarPath, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error: Unable to read %s: %s", tarPath, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// tarAssetName flattens a tar entry path to the name used for its asset.
+func tarAssetName(hdr *tar.Header) string {
+	return path.Base(hdr.Name)
+}
+
+// checkTarAssets makes sure the entries of the tar archive can all be uploaded
+// without their names colliding with each other or with the given files.
+func checkTarAssets(tarPath string, files []assetFile) error {
+	names := make(map[string]string)
+	for _, f := range files {
+		names[f.name] = f.path
+	}
+
+	return walkTar(tarPath, func(hdr *tar.Header, r io.Reader) error {
+		if err := checkAssetSize(hdr.Name, hdr.Size); err != nil {
+			return err
+		}
+
+		name := tarAssetName(hdr)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("Error: %s in %s collides with %s as asset %s", hdr.Name, tarPath, other, name)
+		}
+		names[name] = hdr.Name
+		return nil
+	})
+}
+
+// uploadTar uploads every regular file of the tar archive as a release asset,
+// streaming it straight from the archive. Entries already uploaded with the right
+// size are skipped, as plain files are.
+func uploadTar(release Release, uploadURL, tarPath string, results *uploadResults) error {
+	return walkTar(tarPath, func(hdr *tar.Header, r io.Reader) error {
+		start := time.Now()
+		name := tarAssetName(hdr)
+
+		label, err := replaceAsset(release, name)
+		if err != nil {
+			return fmt.Errorf("Error: Unable to replace %s: %s", name, err)
+		}
+
+		uploaded, err := tarEntryUploaded(release, name, hdr.Size)
+		if err != nil {
+			return fmt.Errorf("Error: Unable to upload %s: %s", name, err)
+		}
+		if uploaded {
+			assetLogf(levelInfo, name, "%s is already uploaded, skipping.\n", name)
+			results.Record(name, hdr.Size, false, nil, time.Since(start))
+			return nil
+		}
+
+		br := bufio.NewReaderSize(r, sniffBytesFlag)
+		head, err := br.Peek(sniffBytesFlag)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		contentType := matchContentTypeRule(assetFile{path: hdr.Name, name: name})
+		if contentType == "" {
+			contentType = contentTypeFlag
+		}
+		if contentType == "" {
+			contentType, err = detectContentType(name, bytes.NewReader(head), -1, len(head))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = uploadAsset(uploadURL, name, label, contentType, br, hdr.Size)
+		invalidateAssets(release)
+		if err == nil && !noAssetVerifyFlag && !dryRunFlag {
+			err = verifyTarAsset(release, name, hdr.Size)
+		}
+		results.Record(name, hdr.Size, err == nil, err, time.Since(start))
+		return err
+	})
+}
+
+// tarEntryUploaded tells whether the release has an asset for the tar entry with the right
+// size, deleting an asset of another size so that the entry can be uploaded again.
+func tarEntryUploaded(release Release, name string, size int64) (bool, error) {
+	asset, err := getAssetByFilename(release, name)
+	if err == errAssetNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if asset.Size == size {
+		return true, nil
+	}
+
+	assetLogf(levelInfo, name, "Deleting asset %s, its size is %d bytes instead of %d.\n", name, asset.Size, size)
+	return false, deleteAsset(release, *asset)
+}
+
+// verifyTarAsset makes sure the asset uploaded for a tar entry has the size of the entry,
+// deleting it otherwise so that the next run uploads it again.
+func verifyTarAsset(release Release, name string, size int64) error {
+	time.Sleep(settleFlag)
+	asset, err := getAssetByFilename(release, name)
+	if err == errAssetNotFound {
+		return fmt.Errorf("Uploaded asset %s cannot be found", name)
+	}
+	if err != nil {
+		return err
+	}
+	if asset.Size != size {
+		if err := deleteAsset(release, *asset); err != nil {
+			return err
+		}
+		return &sizeMismatchError{name: name, expected: size, observed: asset.Size}
+	}
+	return nil
+}
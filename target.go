@@ -0,0 +1,70 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+var shaPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// looksLikeSHA tells whether the target looks like a full or abbreviated commit SHA.
+func looksLikeSHA(target string) bool {
+	return shaPattern.MatchString(target)
+}
+
+// isAbbreviatedSHA tells whether the target looks like an abbreviated commit SHA, which
+// Github doesn't understand as a target.
+func isAbbreviatedSHA(target string) bool {
+	return len(target) < 40 && looksLikeSHA(target)
+}
+
+// releaseTarget returns the target to create the release on, as given, or expanded to a
+// full SHA when an abbreviated one, which Github doesn't understand as a target. The target
+// is looked up for that, or under -verify-target, while full SHAs are given to Github as is.
+func releaseTarget(branch string) (string, error) {
+	if branch == "" || !(verifyTargetFlag || isAbbreviatedSHA(branch)) {
+		return branch, nil
+	}
+
+	start := time.Now()
+	sha, err := resolveCommit(branch)
+	timings.Track("verify target", start)
+	if err != nil {
+		return "", err
+	}
+	infof("Target %s resolves to commit %s.\n", branch, sha)
+
+	if isAbbreviatedSHA(branch) {
+		return sha, nil
+	}
+	return branch, nil
+}
+
+// resolveCommit returns the full SHA of the commit the target, a branch, tag or
+// commit SHA, resolves to.
+func resolveCommit(target string) (string, error) {
+	endpoint := fmt.Sprintf("%s/commits/%s", githubAPIEndpoint, url.PathEscape(target))
+	data, _, err := lookup(endpoint)
+	if hasStatus(err, http.StatusNotFound) || hasStatus(err, http.StatusUnprocessableEntity) {
+		return "", fmt.Errorf("Error: Target %s does not resolve to any commit of %s/%s", target, githubUser, githubRepo)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var commit struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.Unmarshal(data, &commit); err != nil {
+		return "", err
+	}
+	return commit.SHA, nil
+}
@@ -0,0 +1,81 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const fullSHA = "0123456789abcdef0123456789abcdef01234567"
+
+func TestReleaseTargetReachesTargetCommitish(t *testing.T) {
+	var mu sync.Mutex
+	var lookups []string
+	var posted map[string]interface{}
+	useTestServer(t, 5*time.Second, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/repos/o/r/commits/"):
+			ref := strings.TrimPrefix(r.URL.Path, "/repos/o/r/commits/")
+			lookups = append(lookups, ref)
+			if !strings.HasPrefix(fullSHA, strings.ToLower(ref)) && ref != "main" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"message":"No commit found for SHA: ` + ref + `"}`))
+				return
+			}
+			w.Write([]byte(`{"sha":"` + fullSHA + `"}`))
+		case r.Method == "POST" && r.URL.Path == "/repos/o/r/releases":
+			data, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(data, &posted)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":1}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	tests := []struct {
+		branch  string
+		target  string
+		lookups int
+	}{
+		{fullSHA, fullSHA, 0},
+		{"0123456", fullSHA, 1},
+		{"0123456789AB", fullSHA, 1},
+		{"main", "main", 0},
+	}
+	for _, tt := range tests {
+		lookups, posted = nil, nil
+
+		target, err := releaseTarget(tt.branch)
+		if err != nil {
+			t.Errorf("releaseTarget(%s) failed: %s", tt.branch, err)
+			continue
+		}
+		if _, err := createRelease(Release{TagName: "v1", Branch: target}); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := posted["target_commitish"]; got != tt.target {
+			t.Errorf("branch %s sent as target_commitish %v, want %s", tt.branch, got, tt.target)
+		}
+		if len(lookups) != tt.lookups {
+			t.Errorf("branch %s looked up %d times, want %d", tt.branch, len(lookups), tt.lookups)
+		}
+	}
+
+	lookups = nil
+	if _, err := releaseTarget("deadbeef"); err == nil {
+		t.Error("releaseTarget(deadbeef) succeeded, want an error for a SHA of no commit")
+	}
+}
@@ -0,0 +1,73 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// bodyContext holds what a description rendered under -body-template can refer to.
+type bodyContext struct {
+	Tag    string
+	Target string
+	User   string
+	Repo   string
+	Name   string
+	Date   string
+	Env    map[string]string
+}
+
+// Commit returns the full SHA of the commit the release is made on, only looked up on
+// Github when the template refers to it.
+func (c bodyContext) Commit() (string, error) {
+	target := c.Target
+	if target == "" {
+		target = "HEAD"
+	}
+	if len(target) == 40 && looksLikeSHA(target) {
+		return target, nil
+	}
+	return resolveCommit(target)
+}
+
+// renderBody renders the description as a Go template under -body-template, with the
+// details of the release for tag on the given target.
+func renderBody(desc, tag, target string) (string, error) {
+	if !bodyTemplateFlag {
+		return desc, nil
+	}
+
+	tmpl, err := template.New("description").Option("missingkey=zero").Parse(desc)
+	if err != nil {
+		return "", fmt.Errorf("Error: Invalid description template: %s", err)
+	}
+
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	ctx := bodyContext{
+		Tag:    tag,
+		Target: target,
+		User:   githubUser,
+		Repo:   githubRepo,
+		Name:   releaseName(tag),
+		Date:   time.Now().UTC().Format("2006-01-02"),
+		Env:    env,
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, ctx); err != nil {
+		return "", fmt.Errorf("Error: Unable to render the description template: %s", err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,95 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// timing is the time spent in a phase of a release, or uploading an asset.
+type timing struct {
+	Name string `json:"name"`
+	MS   int64  `json:"ms"`
+}
+
+// releaseTimings accumulates the time spent in each phase of a release, and in the
+// upload of each asset, retries included.
+type releaseTimings struct {
+	mu     sync.Mutex
+	start  time.Time
+	phases []timing
+	assets []timing
+}
+
+var timings = &releaseTimings{start: time.Now()}
+
+// Track adds the time elapsed since start to the given phase.
+func (t *releaseTimings) Track(phase string, start time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ms := int64(time.Since(start) / time.Millisecond)
+	for i := range t.phases {
+		if t.phases[i].Name == phase {
+			t.phases[i].MS += ms
+			return
+		}
+	}
+	t.phases = append(t.phases, timing{Name: phase, MS: ms})
+}
+
+// TrackAsset records the time spent uploading an asset.
+func (t *releaseTimings) TrackAsset(name string, elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.assets = append(t.assets, timing{Name: name, MS: int64(elapsed / time.Millisecond)})
+}
+
+// timingsReport is the JSON form of the timings.
+type timingsReport struct {
+	Phases  []timing `json:"phases"`
+	Assets  []timing `json:"assets"`
+	Slowest *timing  `json:"slowest_asset,omitempty"`
+	TotalMS int64    `json:"total_ms"`
+}
+
+func (t *releaseTimings) report() timingsReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := timingsReport{
+		Phases:  append([]timing{}, t.phases...),
+		Assets:  append([]timing{}, t.assets...),
+		TotalMS: int64(time.Since(t.start) / time.Millisecond),
+	}
+	for i := range r.Assets {
+		if r.Slowest == nil || r.Assets[i].MS > r.Slowest.MS {
+			slowest := r.Assets[i]
+			r.Slowest = &slowest
+		}
+	}
+	return r
+}
+
+// Print logs the timing breakdown.
+func (t *releaseTimings) Print() {
+	r := t.report()
+
+	ms := func(n int64) time.Duration { return time.Duration(n) * time.Millisecond }
+	log.Println("Timings:")
+	for _, p := range r.Phases {
+		log.Printf("  %-16s%s\n", p.Name, ms(p.MS))
+	}
+	for _, a := range r.Assets {
+		log.Printf("  %-16s%s\n", "upload "+a.Name, ms(a.MS))
+	}
+	if r.Slowest != nil {
+		log.Printf("  slowest asset:  %s (%s)\n", r.Slowest.Name, ms(r.Slowest.MS))
+	}
+	log.Printf("  %-16s%s\n", "total", ms(r.TotalMS))
+}
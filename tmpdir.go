@@ -0,0 +1,80 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// tempDirs are the temporary directories created by the run, to be removed when it ends.
+var tempDirs struct {
+	sync.Mutex
+	paths []string
+}
+
+// newTempDir creates a temporary directory under -tmp-dir, or the system temporary
+// directory if not set. It is removed by removeTempDirs.
+func newTempDir() (string, error) {
+	dir, err := ioutil.TempDir(tmpDirFlag, "github-release")
+	if err != nil {
+		return "", err
+	}
+
+	tempDirs.Lock()
+	tempDirs.paths = append(tempDirs.paths, dir)
+	tempDirs.Unlock()
+	return dir, nil
+}
+
+// removeTempDirs removes the temporary directories created so far.
+func removeTempDirs() {
+	tempDirs.Lock()
+	defer tempDirs.Unlock()
+
+	for _, dir := range tempDirs.paths {
+		os.RemoveAll(dir)
+	}
+	tempDirs.paths = nil
+}
+
+// removeTempDirsOnInterrupt makes sure temporary directories don't outlive a run
+// that is interrupted, e.g. by a cancelled CI job.
+func removeTempDirsOnInterrupt() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		exit(1)
+	}()
+}
+
+// exit removes the temporary directories before exiting with the given status code,
+// which os.Exit alone would leave behind.
+func exit(code int) {
+	removeTempDirs()
+	os.Exit(code)
+}
+
+// fatal, fatalf and fatalln log like their log.Fatal counterparts, removing the temporary
+// directories before exiting, which log.Fatal alone would leave behind.
+func fatal(v ...interface{}) {
+	log.Print(v...)
+	exit(1)
+}
+
+func fatalf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+	exit(1)
+}
+
+func fatalln(v ...interface{}) {
+	log.Println(v...)
+	exit(1)
+}
@@ -0,0 +1,373 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultMaxAssetSize is the maximum size of a release asset documented by Github.
+const defaultMaxAssetSize = 2 << 30
+
+// checkAssetSizes makes sure none of the files exceeds -max-github-asset-size,
+// so that an oversized file fails the run before anything is uploaded.
+func checkAssetSizes(files []assetFile) error {
+	for _, f := range files {
+		stat, err := os.Stat(f.path)
+		if err != nil {
+			return err
+		}
+		if err := checkAssetSize(f.path, stat.Size()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkAssetSize(name string, size int64) error {
+	if maxAssetSizeFlag > 0 && size > maxAssetSizeFlag {
+		return fmt.Errorf("Error: %s is %d bytes, which exceeds the maximum asset size of %d bytes", name, size, maxAssetSizeFlag)
+	}
+	return nil
+}
+
+// uploadFileWithRetry uploads a file to the release, retrying with exponential backoff, or
+// after the delay Github asked for, when the upload fails or the resulting asset does not
+// have the size of the local file. Error responses which would only come back, like a 401
+// or 403, are not retried.
+// A file already uploaded with the right size is left alone, in which case false is returned.
+// Under -no-asset-verify, a successful upload is trusted without checking the asset size.
+func uploadFileWithRetry(release Release, uploadURL string, file assetFile) (bool, error) {
+	name := file.name
+	backoff := time.Second
+
+	label, err := replaceAsset(release, name)
+	if err != nil {
+		return false, fmt.Errorf("Error: Unable to replace %s: %s", name, err)
+	}
+	if file.label != "" {
+		label = file.label
+	}
+
+	mismatches := 0
+	for attempt := 0; attempt <= uploadRetriesFlag; attempt++ {
+		if attempt > 0 {
+			delay := retryDelay(err, backoff)
+			assetLogf(levelInfo, name, "Retrying upload of %s in %s...\n", name, delay.Round(time.Second))
+			time.Sleep(delay)
+			backoff *= 2
+		}
+
+		var ok bool
+		ok, err = deleteAssetWithWrongFileSize(release, file)
+		if err != nil {
+			assetLogf(levelError, name, "Error: %s\n", err)
+			if !isRetryable(err) {
+				break
+			}
+			continue
+		}
+		if ok {
+			if attempt == 0 {
+				assetLogf(levelInfo, name, "%s is already uploaded, skipping.\n", name)
+			}
+			return attempt > 0, nil
+		}
+
+		err = uploadFile(uploadURL, file, label)
+		invalidateAssets(release)
+		if err != nil {
+			assetLogf(levelError, name, "Error: %s\n", err)
+
+			// The connection may have failed after Github received the whole file,
+			// in which case there is no need to upload it again.
+			if landed, _ := deleteAssetWithWrongFileSize(release, file); landed {
+				assetLogf(levelInfo, name, "%s was uploaded despite the error.\n", name)
+				return true, nil
+			}
+			if !isRetryable(err) {
+				break
+			}
+			continue
+		}
+		if noAssetVerifyFlag || dryRunFlag {
+			return true, nil
+		}
+
+		// Make sure what Github got is what we sent, once it had the time to figure it out.
+		time.Sleep(settleFlag)
+		if err = verifyUploadedAsset(release, file); err == nil {
+			return true, nil
+		}
+		assetLogf(levelError, name, "Error: %s\n", err)
+
+		if _, ok := err.(*sizeMismatchError); ok && strictContentLengthFlag {
+			mismatches++
+			if mismatches > 1 {
+				return false, fmt.Errorf("Error: %s, for the second time. "+
+					"The upload body is likely being altered, e.g. truncated by a proxy, on its way to Github", err)
+			}
+		}
+	}
+	if explained := explainAccessError(err, uploadURL); explained != err {
+		return false, explained
+	}
+	return false, fmt.Errorf("Error: Unable to upload %s: %s", name, err)
+}
+
+// assetPollInterval is the time between two lookups of an asset Github is still processing.
+const assetPollInterval = 2 * time.Second
+
+// waitForAsset polls the release until Github reports the asset as uploaded, rather than
+// still being processed, giving up after timeout.
+func waitForAsset(release Release, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		asset, err := getAssetByFilename(release, name)
+		if err == errAssetNotFound {
+			return fmt.Errorf("Error: Uploaded asset %s cannot be found", name)
+		}
+		if err != nil {
+			return fmt.Errorf("Error: Unable to check the state of %s: %s", name, err)
+		}
+		if asset.State == "uploaded" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Error: Asset %s is still %s after %s", name, asset.State, timeout)
+		}
+
+		assetLogf(levelInfo, name, "Asset %s is %s, waiting for Github to process it...\n", name, asset.State)
+		time.Sleep(assetPollInterval)
+		invalidateAssets(release)
+	}
+}
+
+// assetLogf logs a message of the given level about the upload of the named asset, prefixed
+// with its name when several uploads run at once so that their messages can be told apart.
+func assetLogf(level int, name, format string, args ...interface{}) {
+	if concurrencyFlag > 1 {
+		format = "[" + name + "] " + format
+	}
+	logf(level, format, args...)
+}
+
+// sizeMismatchError reports an uploaded asset whose size differs from the local file's.
+type sizeMismatchError struct {
+	name     string
+	expected int64
+	observed int64
+}
+
+func (e *sizeMismatchError) Error() string {
+	return fmt.Sprintf("Uploaded asset %s has %d bytes instead of %d", e.name, e.observed, e.expected)
+}
+
+// verifyUploadedAsset makes sure the asset uploaded for the file has the size of the
+// file, and its content under -verify-etag, deleting it otherwise so that it can be
+// uploaded again.
+func verifyUploadedAsset(release Release, file assetFile) error {
+	asset, err := getAssetByFilename(release, file.name)
+	if err == errAssetNotFound {
+		return fmt.Errorf("Uploaded asset %s cannot be found", file.name)
+	}
+	if err != nil {
+		return err
+	}
+
+	expected := localSize(file.path)
+	if asset.Size != expected {
+		if err := deleteAsset(release, *asset); err != nil {
+			return err
+		}
+		return &sizeMismatchError{name: file.name, expected: expected, observed: asset.Size}
+	}
+
+	if verifyEtagFlag || verifyChecksumFlag {
+		return verifyAssetDigest(release, *asset, file)
+	}
+	return nil
+}
+
+// verifyAssetDigest compares the SHA-256 digest Github reports for the asset with the one
+// of the file, deleting the asset when they differ. Older Github Enterprise servers don't
+// provide digests, in which case the asset is downloaded back and hashed under
+// -verify-checksum, and not checked otherwise.
+func verifyAssetDigest(release Release, asset Asset, file assetFile) error {
+	digest := strings.TrimPrefix(asset.Digest, "sha256:")
+	if digest == "" || digest == asset.Digest {
+		if !verifyChecksumFlag {
+			if verboseFlag {
+				assetLogf(levelInfo, asset.Name, "Github provides no SHA-256 digest for %s, skipping its verification.\n", asset.Name)
+			}
+			return nil
+		}
+
+		h := sha256.New()
+		if err := downloadAsset(asset, h); err != nil {
+			return err
+		}
+		digest = hex.EncodeToString(h.Sum(nil))
+	}
+
+	sum, err := fileSHA256(file.path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(sum, digest) {
+		return nil
+	}
+
+	if err := deleteAsset(release, asset); err != nil {
+		return err
+	}
+	return fmt.Errorf("Uploaded asset %s has SHA-256 %s instead of %s", asset.Name, digest, sum)
+}
+
+// skipUploadedFiles returns the files not yet uploaded with the right size, based on a
+// single listing of the release assets, which is kept for the uploads to check against.
+// Files already uploaded are recorded as skipped.
+func skipUploadedFiles(release Release, files []assetFile, results *uploadResults) []assetFile {
+	assets, err := cachedAssets(release)
+	if err != nil {
+		fatalln(err)
+	}
+
+	uploaded := make(map[string]Asset, len(assets))
+	for _, a := range assets {
+		uploaded[a.Name] = a
+	}
+
+	var remaining []assetFile
+	for _, f := range files {
+		asset, ok := uploaded[f.name]
+		if ok && asset.Size == localSize(f.path) && !checksumLabelDiffers(asset, f) {
+			infof("%s is already uploaded, skipping.\n", f.name)
+			results.Record(f.name, asset.Size, false, nil, 0)
+			continue
+		}
+		remaining = append(remaining, f)
+	}
+	return remaining
+}
+
+// replaceAsset deletes the asset with the given name under -replace, returning its
+// label so that it can be set again on the new asset.
+func replaceAsset(release Release, name string) (string, error) {
+	if !replaceFlag {
+		return "", nil
+	}
+
+	asset, err := getAssetByFilename(release, name)
+	if err == errAssetNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	assetLogf(levelInfo, name, "Deleting asset %s to replace it.\n", name)
+	return asset.Label, deleteAsset(release, *asset)
+}
+
+// deleteAssetWithWrongFileSize deletes the release asset named after the file if its size
+// differs from the local file's, or its checksum under -checksum-label. It reports whether
+// an asset with the right size is in place.
+func deleteAssetWithWrongFileSize(release Release, file assetFile) (bool, error) {
+	stat, err := os.Stat(file.path)
+	if err != nil {
+		return false, err
+	}
+
+	asset, err := getAssetByFilename(release, file.name)
+	if err == errAssetNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case asset.Size != stat.Size():
+		assetLogf(levelInfo, asset.Name, "Deleting asset %s, its size is %d bytes instead of %d.\n", asset.Name, asset.Size, stat.Size())
+	case checksumLabelDiffers(*asset, file):
+		assetLogf(levelInfo, asset.Name, "Deleting asset %s, its label is %s instead of %s.\n", asset.Name, asset.Label, file.label)
+	default:
+		return true, nil
+	}
+	return false, deleteAsset(release, *asset)
+}
+
+// checksumLabelDiffers tells whether, under -checksum-label, the asset and the file are
+// both labelled with a checksum and these checksums differ.
+func checksumLabelDiffers(asset Asset, file assetFile) bool {
+	if !checksumLabelFlag {
+		return false
+	}
+	return strings.HasPrefix(asset.Label, checksumLabelPrefix) &&
+		strings.HasPrefix(file.label, checksumLabelPrefix) &&
+		asset.Label != file.label
+}
+
+// errAssetNotFound is returned by getAssetByFilename when the release has no asset with the name.
+var errAssetNotFound = errors.New("Asset not found")
+
+// releaseNotFoundError reports a release which no longer exists, e.g. deleted while its
+// assets were being uploaded.
+type releaseNotFoundError struct {
+	release Release
+}
+
+func (e *releaseNotFoundError) Error() string {
+	return fmt.Sprintf("Release %s (id %d) no longer exists", e.release.TagName, e.release.ID)
+}
+
+// transientError reports a lookup which kept failing, despite its retries, in a way that
+// may not happen again, like a 5xx or a dropped connection.
+type transientError struct {
+	release Release
+	err     error
+}
+
+func (e *transientError) Error() string {
+	return fmt.Sprintf("Unable to list the assets of release %s: %s", e.release.TagName, e.err)
+}
+
+// getAssetByFilename returns the release asset with the given name, or errAssetNotFound if
+// there is none. A release which is gone is reported as a releaseNotFoundError, and a failure
+// worth trying again as a transientError.
+func getAssetByFilename(release Release, name string) (*Asset, error) {
+	assets, err := cachedAssets(release)
+	switch {
+	case hasStatus(err, http.StatusNotFound):
+		return nil, &releaseNotFoundError{release}
+	case err != nil && isRetryable(err):
+		return nil, &transientError{release, err}
+	case err != nil:
+		return nil, err
+	}
+
+	for i := range assets {
+		if assets[i].Name == name {
+			return &assets[i], nil
+		}
+	}
+	return nil, errAssetNotFound
+}
+
+// deleteAsset removes an asset from its release.
+func deleteAsset(release Release, asset Asset) error {
+	endpoint := fmt.Sprintf("%s/releases/assets/%d", githubAPIEndpoint, asset.ID)
+	_, err := doRequest("DELETE", endpoint, "application/json", nil, int64(0))
+	invalidateAssets(release)
+	return err
+}
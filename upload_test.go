@@ -0,0 +1,172 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// releaseServer fakes the assets endpoints of release 1.
+type releaseServer struct {
+	mu      sync.Mutex
+	assets  []Asset
+	uploads []url.Values
+	nextID  int64
+	// dropUploads makes the server close the connection instead of answering an upload,
+	// once the asset is stored.
+	dropUploads bool
+}
+
+func (s *releaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/repos/o/r/releases/1/assets":
+		json.NewEncoder(w).Encode(s.assets)
+
+	case r.Method == "POST" && r.URL.Path == "/repos/o/r/releases/1/assets":
+		data, _ := ioutil.ReadAll(r.Body)
+		query := r.URL.Query()
+		s.uploads = append(s.uploads, query)
+		s.nextID++
+		asset := Asset{ID: s.nextID, Name: query.Get("name"), Label: query.Get("label"), Size: int64(len(data)), State: "uploaded"}
+		s.assets = append(s.assets, asset)
+
+		if s.dropUploads {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(asset)
+
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/repos/o/r/releases/assets/"):
+		for i, a := range s.assets {
+			if r.URL.Path == fmt.Sprintf("/repos/o/r/releases/assets/%d", a.ID) {
+				s.assets = append(s.assets[:i], s.assets[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newReleaseServer serves release 1 with the given assets, returning the server along with
+// the URL assets are uploaded to.
+func newReleaseServer(t *testing.T, assets ...Asset) (*releaseServer, string) {
+	s := &releaseServer{assets: assets, nextID: 100}
+	srv := useTestServer(t, 5*time.Second, s.ServeHTTP)
+
+	assetCache.Lock()
+	assetCache.assets = make(map[int64][]Asset)
+	assetCache.Unlock()
+
+	slots := uploadSlots
+	uploadSlots = make(chan struct{}, 1)
+	t.Cleanup(func() { uploadSlots = slots })
+	return s, srv.URL + "/repos/o/r/releases/1/assets"
+}
+
+// writeAssetFile writes a local file to upload as the named asset.
+func writeAssetFile(t *testing.T, name, content string) assetFile {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return assetFile{path: path, name: name}
+}
+
+func TestUploadFileWithRetryTrustsAssetLandedDespiteError(t *testing.T) {
+	s, uploadURL := newReleaseServer(t)
+	s.dropUploads = true
+	file := writeAssetFile(t, "app.zip", "content")
+
+	uploaded, err := uploadFileWithRetry(Release{ID: 1}, uploadURL, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uploaded {
+		t.Error("upload reported as skipped")
+	}
+	if n := len(s.uploads); n != 1 {
+		t.Errorf("file uploaded %d times, want once", n)
+	}
+	if n := len(s.assets); n != 1 {
+		t.Errorf("release has %d assets, want 1", n)
+	}
+}
+
+func TestReplaceAssetKeepsLabel(t *testing.T) {
+	replace := replaceFlag
+	replaceFlag = true
+	defer func() { replaceFlag = replace }()
+
+	s, uploadURL := newReleaseServer(t, Asset{ID: 1, Name: "app.zip", Label: "Linux x86_64", Size: 3})
+	file := writeAssetFile(t, "app.zip", "new content")
+
+	if _, err := uploadFileWithRetry(Release{ID: 1}, uploadURL, file); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.uploads) != 1 {
+		t.Fatalf("file uploaded %d times, want once", len(s.uploads))
+	}
+	if label := s.uploads[0].Get("label"); label != "Linux x86_64" {
+		t.Errorf("asset uploaded with label %q, want the label of the replaced asset", label)
+	}
+	if len(s.assets) != 1 || s.assets[0].ID == 1 || s.assets[0].Label != "Linux x86_64" {
+		t.Errorf("release has assets %+v, want only the new asset, labelled as the old one", s.assets)
+	}
+}
+
+func TestUploadEndpoint(t *testing.T) {
+	const uploadURL = "https://uploads.github.com/repos/o/r/releases/1/assets"
+	tests := []struct {
+		name, label string
+		query       string
+	}{
+		{"app.zip", "", "name=app.zip"},
+		{"my build (1).zip", "", "name=my+build+%281%29.zip"},
+		{"c++-1.0+dev.tar.gz", "", "name=c%2B%2B-1.0%2Bdev.tar.gz"},
+		{"appé-ü-日本.zip", "", "name=app%C3%A9-%C3%BC-%E6%97%A5%E6%9C%AC.zip"},
+		{"a&b=c#d?.zip", "", "name=a%26b%3Dc%23d%3F.zip"},
+		{"app.zip", "Linux x86_64 (static)", "name=app.zip&label=Linux+x86_64+%28static%29"},
+	}
+	for _, tt := range tests {
+		endpoint := uploadEndpoint(uploadURL, tt.name, tt.label)
+		if want := uploadURL + "?" + tt.query; endpoint != want {
+			t.Errorf("uploadEndpoint(%q, %q) = %s, want %s", tt.name, tt.label, endpoint, want)
+		}
+
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			t.Errorf("uploadEndpoint(%q, %q) is not a valid URL: %s", tt.name, tt.label, err)
+			continue
+		}
+		if !strings.HasSuffix(u.Path, "/assets") {
+			t.Errorf("uploadEndpoint(%q, %q) has path %s", tt.name, tt.label, u.Path)
+		}
+		query := u.Query()
+		if got := query.Get("name"); got != tt.name {
+			t.Errorf("uploadEndpoint(%q, %q) sends name %q", tt.name, tt.label, got)
+		}
+		if got := query.Get("label"); got != tt.label {
+			t.Errorf("uploadEndpoint(%q, %q) sends label %q", tt.name, tt.label, got)
+		}
+	}
+}